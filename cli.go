@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"SyncUsingWebDav/pkg/config"
+)
+
+// version 程序版本，发布时通过 -ldflags "-X main.version=v1.2.3" 设置
+var version = "dev"
+
+// progName 帮助信息中显示的程序名
+var progName = filepath.Base(os.Args[0])
+
+// options 命令行参数，全局参数和各个命令的参数写入同一个结构
+type options struct {
+	configFile string
+	sets       config.SetFlags
+	overrides  []string // 对应配置项的命令参数，格式为 配置项=值，优先级高于 -set
+
+	forceDelete bool   // 跳过删除安全检查
+	snapshot    string // 要恢复的快照
+	to          string // restore: 恢复到其他本地目录
+	recursive   bool   // rm: 删除目录
+	long        bool   // ls、tree: 显示大小和修改时间
+	json        bool   // ls、stat、tree、du: 以 JSON 格式输出
+	depth       int    // tree、du: 显示的最大层数，0 表示不限制
+	force       bool   // init: 覆盖已有的配置文件
+	defaults    bool   // init: 不询问，直接写入默认配置
+}
+
+// command 一个子命令
+type command struct {
+	name    string
+	args    string // 位置参数的写法，用于帮助信息
+	summary string
+	local   bool                               // 为 true 时不读取配置文件，不注册 -config 和 -set 参数
+	setup   func(fs *flag.FlagSet, o *options) // 注册命令的参数，可以为空
+	run     func(a *app, args []string) error
+}
+
+// commands 所有子命令，按帮助信息中的顺序排列
+// 在 init 中赋值，因为 help 等命令本身也要查找命令列表
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "sync", summary: "按配置文件中的 mode 同步（不指定命令时的默认命令）", setup: syncFlags, run: runSync},
+		{name: "backup", summary: "备份: 将本地目录同步到服务器", setup: backupFlags, run: runBackup},
+		{name: "restore", args: "[远程路径...]", summary: "恢复: 将服务器上的文件同步到本地，可以只恢复指定的文件或目录", setup: restoreFlags, run: runRestore},
+		{name: "status", summary: "统计本地目录与服务器之间的差异，以及同步将要进行的操作", setup: compareFlags, run: runStatus},
+		{name: "diff", summary: "逐个列出本地目录与服务器之间有差异的条目", setup: compareFlags, run: runDiff},
+		{name: "ls", args: "[远程路径]", summary: "列出服务器上的目录", setup: lsFlags, run: runLs},
+		{name: "stat", args: "<远程路径>", summary: "显示远程文件或目录的信息和服务器返回的全部 WebDAV 属性", setup: jsonFlag, run: runStat},
+		{name: "tree", args: "[远程目录]", summary: "以树状结构递归列出服务器上的目录", setup: treeFlags, run: runTree},
+		{name: "du", args: "[远程目录]", summary: "统计服务器上每个目录占用的空间和文件数", setup: duFlags, run: runDu},
+		{name: "get", args: "<远程文件> [本地路径]", summary: "下载一个远程文件，默认保存到当前目录", run: runGet},
+		{name: "put", args: "<本地文件> [远程路径]", summary: "上传一个本地文件，默认上传到服务器根目录", run: runPut},
+		{name: "rm", args: "<远程路径>...", summary: "删除服务器上的文件或目录（启用历史版本时保存历史版本）", setup: rmFlags, run: runRm},
+		{name: "snapshots", args: "list | diff <快照> [快照] | forget <快照> | prune", summary: "管理服务器上的快照", run: runSnapshots},
+		{name: "trash", args: "list | restore <运行> [路径]", summary: "管理本地回收站", run: runTrash},
+		{name: "init", summary: "交互式生成配置文件", local: true, setup: initFlags, run: runInit},
+		{name: "config", args: "check", summary: "检查全部配置项、读取凭据并测试能否连接服务器", run: runConfig},
+		{name: "version", summary: "显示版本信息", local: true, run: runVersion},
+		{name: "help", args: "[命令]", summary: "显示帮助信息", local: true, run: runHelp},
+	}
+}
+
+// lookupCommand 按名称查找子命令
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// commonFlags 注册所有读取配置文件的命令共用的参数
+func commonFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.configFile, "config", o.configFile, "配置`文件`路径")
+	fs.Var(&o.sets, "set", "覆盖配置项，`配置项=值`，可以重复使用，如 -set retry_delay=5s")
+}
+
+// configFlag 注册一个对应配置项的参数，值的写法与 -set 相同
+func configFlag(fs *flag.FlagSet, o *options, name, key, usage string) {
+	fs.Func(name, fmt.Sprintf("%s (配置项 %s)", usage, key), func(value string) error {
+		o.overrides = append(o.overrides, key+"="+value)
+		return nil
+	})
+}
+
+// configBool 注册一个对应布尔配置项的开关参数，可以写作 -name 或 -name=false
+func configBool(fs *flag.FlagSet, o *options, name, key, usage string) {
+	fs.BoolFunc(name, fmt.Sprintf("%s (配置项 %s)", usage, key), func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		o.overrides = append(o.overrides, key+"="+strconv.FormatBool(b))
+		return nil
+	})
+}
+
+// deleteFlags 注册同步命令共用的删除和并发参数
+func deleteFlags(fs *flag.FlagSet, o *options) {
+	configBool(fs, o, "sync-delete", "sync_delete", "删除目标位置中源位置不存在的文件/目录")
+	fs.BoolVar(&o.forceDelete, "force-delete", o.forceDelete, "跳过删除安全检查，强制删除目标位置中多余的文件/目录")
+	configFlag(fs, o, "concurrency", "max_concurrent", "同时传输的`文件数`")
+	configFlag(fs, o, "local-dir", "local_dir", "本地同步`目录`")
+}
+
+// syncFlags 注册 sync 命令的参数，不指定命令时这些参数可以直接使用
+func syncFlags(fs *flag.FlagSet, o *options) {
+	configFlag(fs, o, "mode", "mode", "同步`模式`: backup (本地->WebDAV)、restore (WebDAV->本地) 或 snapshot (创建快照)")
+	fs.StringVar(&o.snapshot, "snapshot", o.snapshot, "恢复模式下从指定`快照`恢复（快照编号或 latest）")
+	deleteFlags(fs, o)
+}
+
+// backupFlags 注册 backup 命令的参数
+func backupFlags(fs *flag.FlagSet, o *options) {
+	deleteFlags(fs, o)
+	configBool(fs, o, "journal", "use_journal", "使用本地变更索引加速备份")
+	configBool(fs, o, "versioning", "versioning", "覆盖或删除远程文件前保存历史版本")
+}
+
+// restoreFlags 注册 restore 命令的参数
+func restoreFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.to, "to", "", "恢复到指定的本地`目录`，此时不删除文件，也不更新同步状态")
+	fs.StringVar(&o.snapshot, "snapshot", o.snapshot, "从指定`快照`恢复（快照编号或 latest）")
+	deleteFlags(fs, o)
+	configBool(fs, o, "trash", "use_trash", "删除或覆盖本地文件前移入回收站")
+}
+
+// compareFlags 注册 status 和 diff 命令的参数
+func compareFlags(fs *flag.FlagSet, o *options) {
+	configFlag(fs, o, "mode", "mode", "按指定的同步`模式`说明将要进行的操作")
+	configBool(fs, o, "sync-delete", "sync_delete", "说明将要进行的操作时包括删除")
+	configFlag(fs, o, "local-dir", "local_dir", "本地同步`目录`")
+}
+
+// jsonFlag 注册以 JSON 格式输出的参数
+func jsonFlag(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.json, "json", false, "以 JSON 格式输出，便于脚本处理")
+}
+
+// lsFlags 注册 ls 命令的参数
+func lsFlags(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.long, "l", false, "显示大小和修改时间")
+	jsonFlag(fs, o)
+}
+
+// treeFlags 注册 tree 命令的参数
+func treeFlags(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.long, "l", false, "显示文件的大小和修改时间")
+	duFlags(fs, o)
+}
+
+// duFlags 注册 du 命令的参数
+func duFlags(fs *flag.FlagSet, o *options) {
+	fs.IntVar(&o.depth, "depth", 0, "显示的最大`层数`，0 表示不限制")
+	jsonFlag(fs, o)
+}
+
+// rmFlags 注册 rm 命令的参数
+func rmFlags(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.recursive, "r", false, "删除目录及其中的所有内容")
+}
+
+// initFlags 注册 init 命令的参数
+func initFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.configFile, "config", o.configFile, "要生成的配置`文件`路径")
+	fs.BoolVar(&o.force, "force", false, "覆盖已有的配置文件")
+	fs.BoolVar(&o.defaults, "defaults", false, "不询问，直接写入默认配置")
+}
+
+// newFlagSet 创建子命令的参数集合，并设置帮助信息
+func newFlagSet(cmd *command, o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(progName+" "+cmd.name, flag.ExitOnError)
+	if !cmd.local {
+		commonFlags(fs, o)
+	}
+	if cmd.setup != nil {
+		cmd.setup(fs, o)
+	}
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "用法: %s %s [参数] %s\n\n%s\n", progName, cmd.name, cmd.args, cmd.summary)
+		if hasFlags(fs) {
+			fmt.Fprintln(out, "\n参数:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// hasFlags 判断参数集合中是否注册了参数
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// usage 显示程序的帮助信息
+func usage() {
+	out := os.Stderr
+	fmt.Fprintf(out, "用法: %s [-config 文件] [-set 配置项=值]... <命令> [参数]\n\n命令:\n", progName)
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\n运行 %s help <命令> 或 %s <命令> -h 查看命令的参数。\n", progName, progName)
+	fmt.Fprintf(out, "任何配置项都可以通过 SWS_<配置项大写> 环境变量或 -set 参数覆盖，优先级从低到高依次为配置文件、环境变量、-set、命令参数。\n")
+}
+
+// parseArgs 解析命令行，返回要运行的命令及其位置参数
+// 命令之前可以使用 -config、-set 和 sync 命令的参数，不指定命令时运行 sync，兼容旧的用法
+func parseArgs(args []string) (*command, *options, []string) {
+	o := &options{configFile: config.DefaultConfigFile}
+
+	global := flag.NewFlagSet(progName, flag.ExitOnError)
+	commonFlags(global, o)
+	syncFlags(global, o)
+	global.Usage = usage
+	global.Parse(args)
+
+	cmd := lookupCommand("sync")
+	rest := global.Args()
+	if len(rest) > 0 {
+		if cmd = lookupCommand(rest[0]); cmd == nil {
+			fmt.Fprintf(os.Stderr, "未知的命令: %s\n\n", rest[0])
+			usage()
+			os.Exit(2)
+		}
+		rest = rest[1:]
+	}
+
+	fs := newFlagSet(cmd, o)
+	fs.Parse(rest)
+	return cmd, o, fs.Args()
+}
+
+// runHelp 处理 help 命令
+func runHelp(a *app, args []string) error {
+	if len(args) == 0 {
+		usage()
+		return nil
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		return fmt.Errorf("未知的命令: %s", args[0])
+	}
+	newFlagSet(cmd, &options{configFile: config.DefaultConfigFile}).Usage()
+	return nil
+}
+
+// runVersion 处理 version 命令
+func runVersion(a *app, args []string) error {
+	// 没有通过 -ldflags 设置版本时使用构建信息中的模块版本（包括 go install 的版本和基于提交的伪版本）
+	v := version
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v = info.Main.Version
+	}
+	fmt.Printf("%s %s %s %s/%s\n", progName, v, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
+
+// checkArgs 检查位置参数的个数
+func checkArgs(cmd string, args []string, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		c := lookupCommand(cmd)
+		return errors.New(strings.TrimSpace(fmt.Sprintf("用法: %s %s [参数] %s", progName, c.name, c.args)))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runConfig 处理 config 命令
+//
+//	config check  检查配置文件中的所有配置项并测试能否连接服务器，不进行同步
+func runConfig(a *app, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return errors.New("用法: config check")
+	}
+	cfg, err := a.readConfig()
+	if err != nil {
+		return err
+	}
+
+	warnings, err := cfg.Validate()
+	for _, p := range warnings {
+		fmt.Printf("警告: %s\n", p)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("配置项检查通过")
+
+	// 本地同步目录
+	if info, err := os.Stat(cfg.LocalDir); err != nil {
+		fmt.Printf("本地目录 %s 不存在，将在第一次同步时创建\n", cfg.LocalDir)
+	} else if info.IsDir() {
+		probe, err := os.CreateTemp(cfg.LocalDir, ".sws-check-*")
+		if err != nil {
+			return fmt.Errorf("本地目录 %s 不可写: %w", cfg.LocalDir, err)
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+		fmt.Printf("本地目录 %s 可写\n", filepath.Clean(cfg.LocalDir))
+	}
+
+	// 凭据和服务器连接
+	if err := cfg.ResolveSecrets(); err != nil {
+		return fmt.Errorf("读取凭据失败: %w", err)
+	}
+	davClient, err := newWebDAVClient(cfg)
+	if err != nil {
+		return fmt.Errorf("创建WebDAV客户端失败: %w", err)
+	}
+	caps, err := davClient.Probe(cfg.ServerProfile)
+	if err != nil {
+		return fmt.Errorf("无法连接到WebDAV服务器: %w", err)
+	}
+	fmt.Printf("已连接到 %s\n", cfg.WebdavURL)
+	fmt.Printf("服务器配置档案: %s (DAV: %s)\n", caps.Profile.Name, strings.Join(caps.DAV, ", "))
+	if caps.Quota && caps.QuotaAvail >= 0 {
+		fmt.Printf("服务器可用空间: %d 字节\n", caps.QuotaAvail)
+	}
+	fmt.Println("配置检查完成，可以开始同步")
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	syncPkg "SyncUsingWebDav/pkg/sync"
+)
+
+// runGet 处理 get 命令
+//
+//	get <远程文件> [本地路径]  下载一个远程文件，本地路径为目录或省略时保存到该目录（默认当前目录）
+func runGet(a *app, args []string) error {
+	if err := checkArgs("get", args, 1, 2); err != nil {
+		return err
+	}
+	local := "."
+	if len(args) == 2 {
+		local = args[1]
+	}
+	m, err := remoteManager(a)
+	if err != nil {
+		return err
+	}
+	return m.GetFile(args[0], local)
+}
+
+// runPut 处理 put 命令
+//
+//	put <本地文件> [远程路径]  上传一个本地文件，远程路径以 / 结尾、是已有目录或省略时上传到该目录（默认根目录）
+func runPut(a *app, args []string) error {
+	if err := checkArgs("put", args, 1, 2); err != nil {
+		return err
+	}
+	remotePath := "/"
+	if len(args) == 2 {
+		remotePath = args[1]
+	}
+	m, err := remoteManager(a)
+	if err != nil {
+		return err
+	}
+	return m.PutFile(args[0], remotePath)
+}
+
+// runRm 处理 rm 命令
+//
+//	rm [-r] <远程路径>...  删除远程文件，-r 时可以删除目录
+func runRm(a *app, args []string) error {
+	if err := checkArgs("rm", args, 1, -1); err != nil {
+		return err
+	}
+	m, err := remoteManager(a)
+	if err != nil {
+		return err
+	}
+	return m.Remove(args, a.opts.recursive)
+}
+
+// remoteManager 加载配置并连接服务器，返回只用于操作远程文件的同步管理器
+func remoteManager(a *app) (*syncPkg.SyncManager, error) {
+	cfg, err := a.loadConfig("")
+	if err != nil {
+		return nil, err
+	}
+	remote, err := a.connect()
+	if err != nil {
+		return nil, err
+	}
+	return syncPkg.NewSyncManager(remote, cfg), nil
+}
@@ -6,5 +6,6 @@ require github.com/studio-b12/gowebdav v0.10.0
 
 require (
 	github.com/pelletier/go-toml/v2 v2.2.4
-	golang.org/x/net v0.39.0 // indirect
+	golang.org/x/net v0.39.0
+	golang.org/x/text v0.24.0
 )
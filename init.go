@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"SyncUsingWebDav/pkg/config"
+	"SyncUsingWebDav/pkg/secret"
+)
+
+// runInit 处理 init 命令
+//
+//	init [-config 文件] [-force] [-defaults]  逐项询问主要配置并生成配置文件，-defaults 时直接写入默认配置
+func runInit(a *app, args []string) error {
+	if err := checkArgs("init", args, 0, 0); err != nil {
+		return err
+	}
+	file := a.opts.configFile
+	if _, err := os.Stat(file); err == nil && !a.opts.force {
+		return fmt.Errorf("配置文件 %s 已存在，如需重新生成请使用 -force", file)
+	}
+
+	cfg := config.NewDefaultConfig()
+	if !a.opts.defaults {
+		fmt.Printf("生成配置文件 %s，直接回车使用方括号中的默认值\n\n", file)
+		askConfig(cfg, &prompter{in: bufio.NewReader(os.Stdin)})
+	}
+
+	warnings, err := cfg.Validate()
+	for _, p := range warnings {
+		fmt.Printf("警告: %s\n", p)
+	}
+	if err != nil {
+		return err
+	}
+	if err := cfg.SaveToFile(file); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n已创建配置文件 %s，其余配置项使用默认值，可以直接编辑该文件修改\n", file)
+	check := progName + " config check"
+	if file != config.DefaultConfigFile {
+		check = progName + " -config " + file + " config check"
+	}
+	fmt.Printf("运行 %s 检查配置并测试能否连接服务器\n", check)
+	return nil
+}
+
+// askConfig 逐项询问服务器、凭据、同步目录、同步模式和加密配置
+func askConfig(cfg *config.Config, p *prompter) {
+	cfg.WebdavURL = p.ask("WebDAV 服务器地址", cfg.WebdavURL, func(s string) error {
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("地址必须以 http:// 或 https:// 开头")
+		}
+		return nil
+	})
+	cfg.WebdavUsername = p.ask("用户名", cfg.WebdavUsername, nil)
+
+	cfg.WebdavPassword = ""
+	switch p.choose("密码的保存方式 (config: 明文写入配置文件, env: 环境变量, keyring: 系统密钥环, cmd: 密码管理器命令)",
+		"env", "config", "env", "keyring", "cmd") {
+	case "config":
+		cfg.WebdavPassword = p.ask("密码（输入内容会显示在屏幕上）", "", nil)
+	case "env":
+		name := p.ask("保存密码的环境变量名", "WEBDAV_PASSWORD", nil)
+		cfg.WebdavPasswordSource = "env:" + name
+		fmt.Printf("  运行前请设置环境变量 %s\n", name)
+	case "keyring":
+		cfg.WebdavPasswordSource = "keyring"
+		fmt.Printf("  请先保存密码: secret-tool store --label='SyncUsingWebDav' service %s account %s\n",
+			secret.DefaultKeyringService, cfg.WebdavUsername)
+	case "cmd":
+		cfg.WebdavPasswordSource = "cmd:" + p.ask("输出密码的命令，如 pass show webdav", "", required)
+	}
+
+	cfg.LocalDir = p.ask("本地同步目录", cfg.LocalDir, required)
+	cfg.Mode = p.choose("默认同步模式 (backup: 本地->WebDAV, restore: WebDAV->本地, snapshot: 创建快照)",
+		cfg.Mode, string(config.BackupMode), string(config.RestoreMode), string(config.SnapshotMode))
+	cfg.SyncDelete = p.confirm("删除目标位置中源位置不存在的文件", cfg.SyncDelete)
+
+	cfg.Encryption = p.confirm("在上传前加密文件内容", cfg.Encryption)
+	if cfg.Encryption {
+		fmt.Println("  请妥善保存加密口令，丢失后服务器上的文件无法解密")
+		ref := p.ask("加密口令来源 (env:变量名, file:路径, cmd:命令, keyring:服务名/账户名)，留空时直接输入口令", "", func(s string) error {
+			if s == "" {
+				return nil
+			}
+			return secret.CheckRef(s)
+		})
+		if ref != "" {
+			cfg.EncryptionPassphraseSource = ref
+		} else {
+			cfg.EncryptionPassphrase = p.ask("加密口令（输入内容会显示在屏幕上）", "", required)
+		}
+	}
+}
+
+// required 检查回答不为空
+func required(s string) error {
+	if s == "" {
+		return errors.New("不能为空")
+	}
+	return nil
+}
+
+// prompter 在终端中逐行询问配置
+type prompter struct {
+	in  *bufio.Reader
+	eof bool // 输入已结束，之后的问题都使用默认值
+}
+
+// ask 显示问题并读取一行回答，直接回车或输入结束时返回默认值
+// check 不为空时检查回答，无效时重新询问
+func (p *prompter) ask(question, def string, check func(string) error) string {
+	for {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", question, def)
+		} else {
+			fmt.Printf("%s: ", question)
+		}
+
+		answer := def
+		if !p.eof {
+			line, err := p.in.ReadString('\n')
+			if err == io.EOF {
+				p.eof = true
+				fmt.Println()
+			}
+			if line = strings.TrimSpace(line); line != "" {
+				answer = line
+			}
+		} else {
+			fmt.Println()
+		}
+
+		if check == nil {
+			return answer
+		}
+		err := check(answer)
+		if err == nil {
+			return answer
+		}
+		if p.eof {
+			// 没有更多输入，无法重新询问
+			fmt.Printf("  %v，使用 %q\n", err, answer)
+			return answer
+		}
+		fmt.Printf("  %v\n", err)
+	}
+}
+
+// choose 询问一个可选值
+func (p *prompter) choose(question, def string, options ...string) string {
+	return p.ask(question, def, func(s string) error {
+		for _, option := range options {
+			if s == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("请输入 %s 之一", strings.Join(options, ", "))
+	})
+}
+
+// confirm 询问是或否
+func (p *prompter) confirm(question string, def bool) bool {
+	d := "n"
+	if def {
+		d = "y"
+	}
+	answer := p.ask(question+" (y/n)", d, func(s string) error {
+		switch strings.ToLower(s) {
+		case "y", "yes", "n", "no":
+			return nil
+		}
+		return errors.New("请输入 y 或 n")
+	})
+	return strings.HasPrefix(strings.ToLower(answer), "y")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/util"
+)
+
+// remoteEntry ls、stat 和 tree 命令以 JSON 格式输出的远程条目
+// 启用加密时路径和大小为明文形式，启用压缩时大小为服务器上压缩后的大小
+type remoteEntry struct {
+	Path       string            `json:"path"`
+	Name       string            `json:"name"`
+	IsDir      bool              `json:"is_dir"`
+	Size       int64             `json:"size"`
+	Modified   time.Time         `json:"modified,omitzero"`
+	Properties []client.Property `json:"properties,omitempty"` // stat: 服务器返回的全部属性
+	Children   []*remoteEntry    `json:"children,omitempty"`   // tree: 目录中的条目
+}
+
+// newRemoteEntry 将文件信息转换为输出的条目
+func newRemoteEntry(info client.FileInfo) *remoteEntry {
+	return &remoteEntry{
+		Path:     info.Path,
+		Name:     path.Base(info.Path),
+		IsDir:    info.IsDir,
+		Size:     info.Size,
+		Modified: info.LastModified,
+	}
+}
+
+// displayName 返回条目的显示名称，目录以 / 结尾
+func (e *remoteEntry) displayName() string {
+	if e.IsDir && e.Name != "/" {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+// modTime 返回本地时区的修改时间，服务器没有返回时为空
+func (e *remoteEntry) modTime() string {
+	if e.Modified.IsZero() {
+		return "-"
+	}
+	return e.Modified.Local().Format("2006-01-02 15:04:05")
+}
+
+// printJSON 以缩进的 JSON 格式写到标准输出
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// browse 加载配置并连接服务器，供只读取远程文件信息的命令使用
+func browse(a *app) (client.Remote, error) {
+	if _, err := a.loadConfig(""); err != nil {
+		return nil, err
+	}
+	return a.connect()
+}
+
+// remotePathArg 返回位置参数中的远程路径，省略时为根目录
+func remotePathArg(args []string) string {
+	if len(args) == 0 {
+		return "/"
+	}
+	return path.Join("/", args[0])
+}
+
+// runLs 处理 ls 命令，列出服务器上的目录，目录名以 / 结尾；路径为文件时只显示该文件
+//
+//	ls [-l] [-json] [远程路径]  -l 同时显示大小和修改时间
+func runLs(a *app, args []string) error {
+	if err := checkArgs("ls", args, 0, 1); err != nil {
+		return err
+	}
+	remote, err := browse(a)
+	if err != nil {
+		return err
+	}
+
+	info, err := remote.Stat(remotePathArg(args))
+	if err != nil {
+		return err
+	}
+	infos := []client.FileInfo{info}
+	if info.IsDir {
+		if infos, err = remote.ListFiles(info.Path); err != nil {
+			return err
+		}
+	}
+	entries := make([]*remoteEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, newRemoteEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	if a.opts.json {
+		return printJSON(entries)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, entry := range entries {
+		if a.opts.long {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", entry.Size, entry.modTime(), entry.displayName())
+		} else {
+			fmt.Fprintln(w, entry.displayName())
+		}
+	}
+	return w.Flush()
+}
+
+// runStat 处理 stat 命令，显示远程文件或目录的信息，以及服务器通过 PROPFIND 返回的全部属性
+//
+//	stat [-json] <远程路径>
+func runStat(a *app, args []string) error {
+	if err := checkArgs("stat", args, 1, 1); err != nil {
+		return err
+	}
+	remote, err := browse(a)
+	if err != nil {
+		return err
+	}
+
+	info, err := remote.Stat(remotePathArg(args))
+	if err != nil {
+		return err
+	}
+	entry := newRemoteEntry(info)
+	if entry.Properties, err = remote.AllProperties(info.Path); err != nil {
+		return err
+	}
+
+	if a.opts.json {
+		return printJSON(entry)
+	}
+	kind := "文件"
+	if entry.IsDir {
+		kind = "目录"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "路径:\t%s\n", entry.Path)
+	fmt.Fprintf(w, "类型:\t%s\n", kind)
+	if !entry.IsDir {
+		fmt.Fprintf(w, "大小:\t%d (%s)\n", entry.Size, util.FormatSize(entry.Size))
+	}
+	fmt.Fprintf(w, "修改时间:\t%s\n", entry.modTime())
+	fmt.Fprintln(w, "属性:")
+	for _, p := range entry.Properties {
+		fmt.Fprintf(w, "  {%s}%s\t%s\n", p.Namespace, p.Name, p.Value)
+	}
+	return w.Flush()
+}
@@ -2,57 +2,198 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/compress"
 	"SyncUsingWebDav/pkg/config"
+	"SyncUsingWebDav/pkg/crypt"
+	"SyncUsingWebDav/pkg/secret"
 	syncPkg "SyncUsingWebDav/pkg/sync"
 )
 
 func main() {
-	// 创建默认配置
+	cmd, opts, args := parseArgs(os.Args[1:])
+	if err := cmd.run(&app{opts: opts}, args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// app 运行子命令所需的配置和服务器连接，按需加载
+type app struct {
+	opts   *options
+	cfg    *config.Config
+	remote client.Remote
+}
+
+// readConfig 加载配置文件，并应用环境变量和命令行参数，不检查配置项
+func (a *app) readConfig() (*config.Config, error) {
+	if a.cfg != nil {
+		return a.cfg, nil
+	}
 	cfg := config.NewDefaultConfig()
+	if err := cfg.Load(a.opts.configFile, append(a.opts.sets, a.opts.overrides...)); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("配置文件 %s 不存在，请先运行 %s init 生成配置文件", a.opts.configFile, progName)
+		}
+		return nil, fmt.Errorf("加载配置失败: %w", err)
+	}
+	cfg.ForceDelete = a.opts.forceDelete
+	cfg.Snapshot = a.opts.snapshot
+	a.cfg = cfg
+	return cfg, nil
+}
 
-	// 从命令行参数和配置文件加载配置
-	// 如果配置文件不存在，将创建默认配置并退出程序
-	cfg.LoadFromArgs()
+// loadConfig 加载配置并检查全部配置项，存在致命问题时返回错误
+// mode 不为空时覆盖配置中的同步模式
+func (a *app) loadConfig(mode config.SyncMode) (*config.Config, error) {
+	cfg, err := a.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	if mode != "" {
+		cfg.Mode = string(mode)
+	}
 
-	// 显示当前模式
-	if cfg.Mode == string(config.BackupMode) {
-		fmt.Printf("运行模式: 备份 (本地->WebDAV)\n")
-	} else {
-		fmt.Printf("运行模式: 恢复 (WebDAV->本地)\n")
+	warnings, err := cfg.Validate()
+	for _, p := range warnings {
+		a.infof("警告: %s\n", p)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return cfg, nil
+}
 
-	if cfg.SyncDelete {
-		fmt.Printf("启用删除操作: 目标位置中源位置不存在的文件将被删除\n")
-	} else {
-		fmt.Printf("未启用删除操作: 仅同步文件，不会删除目标位置的文件\n")
+// connect 读取凭据、连接服务器并按配置叠加加密层和压缩层，调用前需要先加载配置
+func (a *app) connect() (client.Remote, error) {
+	if a.remote != nil {
+		return a.remote, nil
 	}
+	cfg := a.cfg
 
-	// 确保本地同步目录存在
-	if err := cfg.EnsureLocalDir(); err != nil {
-		log.Fatalf("创建本地目录失败: %v", err)
+	// 从配置的凭据来源读取密码
+	if err := cfg.ResolveSecrets(); err != nil {
+		return nil, fmt.Errorf("读取凭据失败: %w", err)
 	}
 
 	// 创建WebDAV客户端
-	davClient := client.NewWebDAVClient(
-		cfg.WebdavURL,
-		cfg.WebdavUsername,
-		cfg.WebdavPassword,
-	)
-
-	// 测试WebDAV连接
-	_, err := davClient.FileExists("/")
+	davClient, err := newWebDAVClient(cfg)
 	if err != nil {
-		log.Fatalf("无法连接到WebDAV服务器: %v", err)
+		return nil, fmt.Errorf("创建WebDAV客户端失败: %w", err)
+	}
+
+	// 连接WebDAV服务器并探测服务器能力
+	caps, err := davClient.Probe(cfg.ServerProfile)
+	if err != nil {
+		return nil, fmt.Errorf("无法连接到WebDAV服务器: %w", err)
+	}
+	a.infof("服务器配置档案: %s (DAV: %s)\n", caps.Profile.Name, strings.Join(caps.DAV, ", "))
+
+	// 按服务器限制调整并发数
+	if limit := caps.Profile.MaxConcurrent; limit > 0 && cfg.MaxConcurrent > limit {
+		a.infof("服务器 %s 建议最大并发数为 %d，已自动调整\n", caps.Profile.Name, limit)
+		cfg.MaxConcurrent = limit
+	}
+
+	// 启用加密时在客户端之上叠加加密层
+	var remote client.Remote = davClient
+	if cfg.Encryption {
+		names, err := crypt.ParseNameMode(cfg.EncryptionNames)
+		if err != nil {
+			return nil, fmt.Errorf("加密配置无效: %w", err)
+		}
+		remote, err = crypt.Open(davClient, crypt.Options{
+			Passphrase: cfg.EncryptionPassphrase,
+			KeyFile:    cfg.EncryptionKeyFile,
+			Names:      names,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("初始化加密失败: %w", err)
+		}
+		a.infof("已启用客户端加密 (文件名: %s)\n", names)
+	}
+
+	// 启用压缩时在加密层之上叠加压缩层，保证先压缩再加密
+	switch cfg.Compression {
+	case "", "off":
+	case "gzip":
+		remote, err = compress.New(remote, compress.Options{
+			Patterns: cfg.CompressPatterns,
+			Level:    cfg.CompressLevel,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("压缩配置无效: %w", err)
+		}
+		a.infof("已启用压缩 (gzip): %s\n", strings.Join(cfg.CompressPatterns, ", "))
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法: %s (可选 off, gzip)", cfg.Compression)
 	}
 
-	// 创建同步管理器
-	syncManager := syncPkg.NewSyncManager(davClient, cfg)
+	a.remote = remote
+	return remote, nil
+}
 
-	// 开始同步过程
-	if err := syncManager.StartSync(); err != nil {
-		log.Fatalf("同步失败: %v", err)
+// infof 输出配置和连接相关的提示，-json 时写到标准错误，避免混入 JSON 输出
+func (a *app) infof(format string, args ...any) {
+	var out io.Writer = os.Stdout
+	if a.opts.json {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// manager 确保本地同步目录存在并连接服务器，返回同步管理器，调用前需要先加载配置
+func (a *app) manager() (*syncPkg.SyncManager, error) {
+	// 确保本地同步目录存在
+	if err := a.cfg.EnsureLocalDir(); err != nil {
+		return nil, fmt.Errorf("创建本地目录失败: %w", err)
+	}
+
+	remote, err := a.connect()
+	if err != nil {
+		return nil, err
+	}
+	return syncPkg.NewSyncManager(remote, a.cfg), nil
+}
+
+// newWebDAVClient 按配置创建WebDAV客户端，调用前需要先读取凭据
+func newWebDAVClient(cfg *config.Config) (*client.WebDAVClient, error) {
+	// 认证请求头与其他请求头同名时以认证请求头为准
+	headers := make(map[string]string)
+	for key, value := range cfg.Headers {
+		headers[key] = value
+	}
+	for key, value := range cfg.AuthHeaders {
+		headers[key] = value
+	}
+	opts := client.Options{
+		Username:              cfg.WebdavUsername,
+		Password:              cfg.WebdavPassword,
+		BearerToken:           cfg.BearerToken,
+		Headers:               headers,
+		ClientCert:            cfg.TLSClientCert,
+		ClientKey:             cfg.TLSClientKey,
+		CACert:                cfg.TLSCACert,
+		PinSHA256:             cfg.TLSPinSHA256,
+		InsecureSkipVerify:    cfg.TLSInsecureSkipVerify,
+		Proxy:                 cfg.Proxy,
+		MaxConns:              cfg.MaxConcurrent + 2, // 并发传输之外还有目录列表等请求
+		ConnectTimeout:        time.Duration(cfg.ConnectTimeout),
+		TLSHandshakeTimeout:   time.Duration(cfg.TLSHandshakeTimeout),
+		ResponseHeaderTimeout: time.Duration(cfg.ResponseHeaderTimeout),
+		IdleConnTimeout:       time.Duration(cfg.IdleConnTimeout),
+		HTTP2:                 cfg.HTTP2,
+		UserAgent:             cfg.UserAgent,
+	}
+	if command := cfg.BearerTokenCommand; command != "" {
+		opts.RefreshToken = func() (string, error) {
+			return secret.RunCommand(command)
+		}
 	}
+	return client.NewWebDAVClient(cfg.WebdavURL, opts)
 }
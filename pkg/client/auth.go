@@ -0,0 +1,135 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// tokenSource 在所有请求之间共享的 Bearer 令牌，服务器返回 401 时通过 refresh 获取新令牌
+type tokenSource struct {
+	mu      sync.Mutex
+	token   string
+	refresh func() (string, error) // 可以为 nil，此时令牌无法刷新
+}
+
+// current 返回当前令牌
+func (t *tokenSource) current() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.token
+}
+
+// renew 在令牌仍为 used 时获取新令牌，并发请求同时收到 401 时只刷新一次
+// 返回是否得到了可以重试的新令牌
+func (t *tokenSource) renew(used string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != used {
+		return true
+	}
+	if t.refresh == nil {
+		return false
+	}
+	token, err := t.refresh()
+	if err != nil {
+		log.Printf("警告: 刷新访问令牌失败: %v", err)
+		return false
+	}
+	if token == "" || token == used {
+		return false
+	}
+	t.token = token
+	log.Printf("已刷新访问令牌")
+	return true
+}
+
+// tokenAuthorizer 为每个请求添加 Authorization: Bearer 头的认证器，实现 gowebdav.Authorizer
+// 与gowebdav的认证器一样缓冲请求体，刷新令牌后可以重新发送请求
+type tokenAuthorizer struct {
+	source *tokenSource
+}
+
+// newTokenAuthorizer 创建 Bearer 令牌认证器，token 为空且可以刷新时先获取一次令牌
+func newTokenAuthorizer(token string, refresh func() (string, error)) (*tokenAuthorizer, error) {
+	if token == "" && refresh != nil {
+		var err error
+		if token, err = refresh(); err != nil {
+			return nil, fmt.Errorf("获取访问令牌失败: %w", err)
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("获取访问令牌失败: 令牌为空")
+	}
+	return &tokenAuthorizer{source: &tokenSource{token: token, refresh: refresh}}, nil
+}
+
+// NewAuthenticator 为单个请求创建认证器，不能定位的请求体在发送时同时写入缓冲区，重试时使用缓冲区的内容
+func (a *tokenAuthorizer) NewAuthenticator(body io.Reader) (gowebdav.Authenticator, io.Reader) {
+	retry := body
+	if body != nil {
+		if _, ok := body.(io.Seeker); !ok {
+			buf := &bytes.Buffer{}
+			retry = buf
+			body = io.TeeReader(body, buf)
+		}
+	}
+	return &tokenAuth{source: a.source, body: retry}, body
+}
+
+// AddAuthenticator 令牌认证只有一种方式，不支持注册其他认证方式
+func (a *tokenAuthorizer) AddAuthenticator(key string, fn gowebdav.AuthFactory) {
+	panic("tokenAuthorizer 不支持注册其他认证方式")
+}
+
+// tokenAuth 单个请求的 Bearer 令牌认证
+type tokenAuth struct {
+	source  *tokenSource
+	body    io.Reader // 重试时使用的请求体
+	used    string    // 本次请求使用的令牌
+	renewed bool      // 本次请求已经刷新过令牌
+}
+
+// Authorize 添加 Authorization 头，并设置重试时获取请求体的方法
+func (t *tokenAuth) Authorize(c *http.Client, rq *http.Request, path string) error {
+	t.used = t.source.current()
+	rq.Header.Set("Authorization", "Bearer "+t.used)
+
+	body := t.body
+	rq.GetBody = func() (io.ReadCloser, error) {
+		if body == nil {
+			return http.NoBody, nil
+		}
+		if sk, ok := body.(io.Seeker); ok {
+			if _, err := sk.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		return io.NopCloser(body), nil
+	}
+	return nil
+}
+
+// Verify 服务器返回 401 时刷新令牌并重新发送一次请求
+func (t *tokenAuth) Verify(c *http.Client, rs *http.Response, path string) (bool, error) {
+	if rs.StatusCode != http.StatusUnauthorized || t.renewed {
+		return false, nil
+	}
+	t.renewed = true
+	return t.source.renew(t.used), nil
+}
+
+// Clone 返回共享同一令牌的新认证器
+func (t *tokenAuth) Clone() gowebdav.Authenticator {
+	return &tokenAuth{source: t.source}
+}
+
+// Close 实现 io.Closer
+func (t *tokenAuth) Close() error {
+	return nil
+}
@@ -0,0 +1,235 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 服务器配置档案名称
+const (
+	ProfileAuto       = "auto"
+	ProfileGeneric    = "generic"
+	ProfileNextcloud  = "nextcloud"
+	ProfileOwnCloud   = "owncloud"
+	ProfileAList      = "alist"
+	ProfileJianguoyun = "jianguoyun"
+)
+
+// Profile 服务器配置档案，描述已知服务器的能力和限制
+type Profile struct {
+	Name string
+
+	OCMtime        bool // 支持通过 X-OC-Mtime 请求头设置修改时间
+	PropPatchMtime bool // 允许通过 PROPPATCH 修改 getlastmodified
+	Chunking       bool // 支持分块上传
+	Checksums      bool // 支持 OC-Checksum 校验和
+	DepthInfinity  bool // 支持 Depth: infinity 的 PROPFIND
+
+	MaxConcurrent   int           // 建议的最大并发数，0 表示不限制
+	RequestInterval time.Duration // 两次请求之间的最小间隔，用于避免触发服务器限流
+	ListLimit       int           // 单次列目录返回条目数上限，0 表示无上限
+}
+
+// profiles 已知服务器的配置档案
+var profiles = map[string]Profile{
+	ProfileGeneric: {
+		Name:           ProfileGeneric,
+		PropPatchMtime: true,
+	},
+	ProfileNextcloud: {
+		Name:      ProfileNextcloud,
+		OCMtime:   true,
+		Chunking:  true,
+		Checksums: true,
+	},
+	ProfileOwnCloud: {
+		Name:      ProfileOwnCloud,
+		OCMtime:   true,
+		Chunking:  true,
+		Checksums: true,
+	},
+	ProfileAList: {
+		// AList 不支持 PROPPATCH，但上传时会读取 X-OC-Mtime
+		Name:    ProfileAList,
+		OCMtime: true,
+	},
+	ProfileJianguoyun: {
+		// 坚果云对请求频率有限制，单个目录最多返回 750 个条目
+		Name:            ProfileJianguoyun,
+		MaxConcurrent:   2,
+		RequestInterval: 200 * time.Millisecond,
+		ListLimit:       750,
+	},
+}
+
+// Capabilities 连接时探测到的服务器能力
+type Capabilities struct {
+	Profile Profile // 最终使用的配置档案
+
+	DAV    []string // DAV 响应头中的合规等级和扩展
+	Allow  []string // 服务器允许的方法
+	Server string   // Server 响应头
+
+	Lock          bool // 支持 LOCK（DAV 等级 2）
+	DepthInfinity bool // 支持 Depth: infinity 的 PROPFIND
+	Quota         bool // 支持配额属性
+	QuotaUsed     int64
+	QuotaAvail    int64 // 可用空间，负数表示未知或不限
+}
+
+// LookupProfile 按名称查找服务器配置档案
+func LookupProfile(name string) (Profile, bool) {
+	p, ok := profiles[strings.ToLower(name)]
+	return p, ok
+}
+
+// ProfileNames 返回所有可用的配置档案名称（包括 auto）
+func ProfileNames() []string {
+	names := []string{ProfileAuto}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// propfindQuotaBody 探测配额属性的 PROPFIND 请求体
+const propfindQuotaBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+	<d:prop>
+		<d:resourcetype/>
+		<d:quota-used-bytes/>
+		<d:quota-available-bytes/>
+	</d:prop>
+</d:propfind>`
+
+// Probe 探测服务器能力并应用配置档案
+// profileName 为空或 auto 时根据探测结果自动选择配置档案
+func (c *WebDAVClient) Probe(profileName string) (*Capabilities, error) {
+	caps := &Capabilities{QuotaAvail: -1}
+
+	// OPTIONS 获取 DAV 等级和允许的方法
+	resp, err := c.request("OPTIONS", "/", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("连接服务器失败: %w", wrapError("OPTIONS", "/", err))
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("连接服务器失败: %w", newStatusError("OPTIONS", "/", resp))
+	}
+	caps.DAV = splitHeader(resp.Header.Values("DAV"))
+	caps.Allow = splitHeader(resp.Header.Values("Allow"))
+	caps.Server = resp.Header.Get("Server")
+	for _, class := range caps.DAV {
+		if class == "2" {
+			caps.Lock = true
+		}
+	}
+
+	// PROPFIND 根目录，确认地址指向一个集合并读取配额
+	responses, err := c.propfind("/", "0", propfindQuotaBody)
+	if err != nil {
+		return nil, fmt.Errorf("读取根目录失败: %w", err)
+	}
+	if len(responses) > 0 {
+		props := responses[0].okProps()
+		if !strings.Contains(props["DAV: resourcetype"], "collection") {
+			return nil, fmt.Errorf("WebDAV地址 %s 不是一个目录", c.root)
+		}
+		if used, ok := props["DAV: quota-used-bytes"]; ok {
+			caps.Quota = true
+			caps.QuotaUsed, _ = strconv.ParseInt(used, 10, 64)
+		}
+		if avail, ok := props["DAV: quota-available-bytes"]; ok {
+			caps.Quota = true
+			caps.QuotaAvail, _ = strconv.ParseInt(avail, 10, 64)
+		}
+	}
+
+	// 选择配置档案
+	if profileName == "" || strings.EqualFold(profileName, ProfileAuto) {
+		profileName = c.detectProfile(caps)
+	}
+	profile, ok := LookupProfile(profileName)
+	if !ok {
+		return nil, fmt.Errorf("未知的服务器配置档案: %s", profileName)
+	}
+	caps.Profile = profile
+
+	// 通用服务器需要实际探测是否支持无限深度的 PROPFIND
+	caps.DepthInfinity = profile.DepthInfinity
+	if profile.Name == ProfileGeneric {
+		caps.DepthInfinity = c.probeDepthInfinity()
+	}
+
+	c.applyProfile(caps)
+	return caps, nil
+}
+
+// detectProfile 根据地址和响应头推断服务器类型
+func (c *WebDAVClient) detectProfile(caps *Capabilities) string {
+	u, _ := url.Parse(c.root)
+	host := strings.ToLower(u.Hostname())
+	davHeader := strings.ToLower(strings.Join(caps.DAV, ","))
+
+	switch {
+	case strings.HasSuffix(host, "jianguoyun.com"):
+		return ProfileJianguoyun
+	case strings.Contains(davHeader, "nextcloud") || strings.Contains(davHeader, "nc-"):
+		return ProfileNextcloud
+	case strings.Contains(u.Path, "/remote.php/"):
+		return ProfileOwnCloud
+	case strings.Contains(strings.ToLower(caps.Server), "alist") || u.Port() == "5244":
+		return ProfileAList
+	default:
+		return ProfileGeneric
+	}
+}
+
+// probeDepthInfinity 检查服务器是否接受 Depth: infinity 的 PROPFIND
+// 只读取状态码，不解析可能很大的响应体
+func (c *WebDAVClient) probeDepthInfinity() bool {
+	resp, err := c.request("PROPFIND", "/", strings.NewReader(propfindBasicBody), func(req *http.Request) {
+		req.Header.Set("Depth", "infinity")
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	})
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusMultiStatus
+}
+
+// applyProfile 根据配置档案调整客户端行为
+func (c *WebDAVClient) applyProfile(caps *Capabilities) {
+	c.caps = caps
+	if !caps.Profile.PropPatchMtime {
+		c.noPropPatch.Store(true)
+	}
+	c.transport.setInterval(caps.Profile.RequestInterval)
+}
+
+// Capabilities 返回探测到的服务器能力，尚未探测时返回 nil
+func (c *WebDAVClient) Capabilities() *Capabilities {
+	return c.caps
+}
+
+// splitHeader 将逗号分隔的响应头拆分为去除空白的列表
+func splitHeader(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
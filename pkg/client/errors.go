@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// 可以用 errors.Is 判断的WebDAV错误类别
+var (
+	ErrNotFound            = errors.New("资源不存在")
+	ErrUnauthorized        = errors.New("认证失败")
+	ErrForbidden           = errors.New("没有访问权限")
+	ErrConflict            = errors.New("资源冲突")
+	ErrInsufficientStorage = errors.New("服务器存储空间不足")
+	ErrLocked              = errors.New("资源已被锁定")
+	ErrPreconditionFailed  = errors.New("前置条件不满足")
+	ErrRateLimited         = errors.New("请求过于频繁")
+
+	// ErrPropsUnsupported 服务器不允许保存自定义属性（dead property），不对应特定的状态码
+	ErrPropsUnsupported = errors.New("服务器不支持自定义属性")
+)
+
+// Error WebDAV请求失败时返回的错误，携带HTTP状态码、方法和路径
+type Error struct {
+	Method     string
+	Path       string
+	Status     int           // HTTP状态码，网络等非HTTP错误时为 0
+	RetryAfter time.Duration // 服务器通过 Retry-After 要求的等待时间
+	Err        error         // 底层错误，可能为 nil
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	switch {
+	case e.Status != 0 && e.Err != nil:
+		return fmt.Sprintf("%s %s: %d %s: %v", e.Method, e.Path, e.Status, http.StatusText(e.Status), e.Err)
+	case e.Status != 0:
+		return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.Status, http.StatusText(e.Status))
+	default:
+		return fmt.Sprintf("%s %s: %v", e.Method, e.Path, e.Err)
+	}
+}
+
+// Unwrap 返回底层错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is 按状态码匹配错误类别
+func (e *Error) Is(target error) bool {
+	return e.Status != 0 && statusSentinel(e.Status) == target
+}
+
+// Retryable 判断错误是否值得重试，供 util.Retry 使用
+// 网络错误、限流、锁定和服务器内部错误可以重试，其他客户端错误重试也不会成功
+func (e *Error) Retryable() bool {
+	switch {
+	case e.Status == 0:
+		return true
+	case e.Status == http.StatusTooManyRequests, e.Status == http.StatusLocked,
+		e.Status == http.StatusRequestTimeout:
+		return true
+	case e.Status == http.StatusInsufficientStorage:
+		return false
+	default:
+		return e.Status >= 500
+	}
+}
+
+// RetryDelay 返回服务器要求的重试等待时间，供 util.Retry 使用
+func (e *Error) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// statusSentinel 返回状态码对应的错误类别
+func statusSentinel(status int) error {
+	switch status {
+	case http.StatusNotFound, http.StatusGone:
+		return ErrNotFound
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusInsufficientStorage:
+		return ErrInsufficientStorage
+	case http.StatusLocked:
+		return ErrLocked
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+// newStatusError 根据响应创建错误，并解析 Retry-After
+func newStatusError(method, remotePath string, resp *http.Response) error {
+	e := &Error{Method: method, Path: remotePath, Status: resp.StatusCode}
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			e.RetryAfter = time.Duration(seconds) * time.Second
+		} else if t, err := http.ParseTime(value); err == nil {
+			e.RetryAfter = time.Until(t)
+		}
+	}
+	return e
+}
+
+// wrapError 将gowebdav或网络错误包装为 *Error
+func wrapError(method, remotePath string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		var statusErr gowebdav.StatusError
+		if errors.As(pathErr.Err, &statusErr) {
+			return &Error{Method: method, Path: remotePath, Status: statusErr.Status}
+		}
+		return &Error{Method: method, Path: remotePath, Err: pathErr.Err}
+	}
+	return &Error{Method: method, Path: remotePath, Err: err}
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// WriteLocalFile 将数据流写入本地文件并设置修改时间
+// 先写入临时文件再原子性地替换目标文件，写入失败时不会破坏已有文件
+func WriteLocalFile(localPath string, reader io.Reader, modTime time.Time) (err error) {
+	// 创建临时文件
+	tmpFile := localPath + ".download"
+	file, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+
+	// 确保在函数返回前关闭并删除临时文件（如果出错）
+	defer func() {
+		file.Close()
+		if err != nil {
+			os.Remove(tmpFile)
+		}
+	}()
+
+	// 直接复制文件
+	if _, err = io.Copy(file, reader); err != nil {
+		return err
+	}
+
+	// 关闭文件
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	// 原子性地替换文件
+	if err = os.Rename(tmpFile, localPath); err != nil {
+		return err
+	}
+
+	// 设置文件修改时间
+	return os.Chtimes(localPath, modTime, modTime)
+}
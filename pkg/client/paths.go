@@ -0,0 +1,131 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// PathError 服务器返回的 href 无法表示为远程路径时返回的错误
+type PathError struct {
+	Href   string // 服务器返回的原始 href
+	Reason string
+}
+
+// Error 实现 error 接口
+func (e *PathError) Error() string {
+	return fmt.Sprintf("无法表示的远程路径 %q: %s", e.Href, e.Reason)
+}
+
+// baseURL 解析WebDAV根地址，返回编码后的地址前缀（以 / 结尾）和解码后的根路径各级名称
+// 根地址中已经编码的字符保持原样，未编码的空格、中文等字符按需编码，避免重复编码
+func baseURL(root string) (string, []string) {
+	u, err := url.Parse(root)
+	if err != nil {
+		return strings.TrimSuffix(root, "/") + "/", nil
+	}
+
+	var segments []string
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+
+	escaped := strings.TrimSuffix(u.EscapedPath(), "/") + "/"
+	return u.Scheme + "://" + u.Host + escaped, segments
+}
+
+// escapeSegment 对路径中的一级名称进行百分号编码
+// 除 RFC 3986 的非保留字符外全部编码，避免服务器把 +、;、% 等字符理解为特殊含义
+func escapeSegment(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+			ch == '-' || ch == '.' || ch == '_' || ch == '~' {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// encodePath 将以 / 分隔的远程路径转换为请求使用的完整URL
+// 每一级名称单独编码，路径以 / 结尾时保留结尾的 /
+func (c *WebDAVClient) encodePath(remotePath string) string {
+	var b strings.Builder
+	b.WriteString(c.base)
+	first := true
+	for _, seg := range strings.Split(remotePath, "/") {
+		if seg == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte('/')
+		}
+		first = false
+		b.WriteString(escapeSegment(seg))
+	}
+	if !first && strings.HasSuffix(remotePath, "/") {
+		b.WriteByte('/')
+	}
+	return b.String()
+}
+
+// decodeHref 将多状态响应中的 href 转换为相对于WebDAV根地址、以 / 开头的路径
+// href 可以是完整URL或绝对路径；问号和井号按名称中的普通字符处理，因为部分服务器不对它们编码
+// 百分号编码无效、名称中含有编码的斜杠或空字符、不是有效的UTF-8、不在根路径下时返回 *PathError
+func (c *WebDAVClient) decodeHref(href string) (string, error) {
+	raw := href
+	if i := strings.Index(raw, "://"); i >= 0 {
+		raw = raw[i+3:]
+		if j := strings.IndexByte(raw, '/'); j >= 0 {
+			raw = raw[j:]
+		} else {
+			raw = "/"
+		}
+	}
+
+	var names []string
+	for _, seg := range strings.Split(raw, "/") {
+		if seg == "" {
+			continue
+		}
+		name, err := url.PathUnescape(seg)
+		if err != nil {
+			return "", &PathError{Href: href, Reason: fmt.Sprintf("百分号编码无效 (%s)", seg)}
+		}
+		switch {
+		case strings.Contains(name, "/"):
+			return "", &PathError{Href: href, Reason: fmt.Sprintf("名称 %q 中含有编码的斜杠", name)}
+		case strings.Contains(name, "\x00"):
+			return "", &PathError{Href: href, Reason: fmt.Sprintf("名称 %q 中含有空字符", name)}
+		case !utf8.ValidString(name):
+			return "", &PathError{Href: href, Reason: fmt.Sprintf("名称 %q 不是有效的UTF-8编码", name)}
+		case name == "." || name == "..":
+			return "", &PathError{Href: href, Reason: "路径中含有 . 或 .."}
+		}
+		names = append(names, name)
+	}
+
+	// 去掉根地址部分，部分服务器返回的根路径大小写与配置不同
+	if len(names) < len(c.rootNames) {
+		return "", &PathError{Href: href, Reason: "不在WebDAV根路径下"}
+	}
+	for i, name := range c.rootNames {
+		if name != names[i] && !strings.EqualFold(name, names[i]) {
+			return "", &PathError{Href: href, Reason: "不在WebDAV根路径下"}
+		}
+	}
+	return "/" + strings.Join(names[len(c.rootNames):], "/"), nil
+}
+
+// reportPath 记录无法表示的远程路径，该条目被跳过，所在目录的列表视为不完整
+func (c *WebDAVClient) reportPath(dir string, err error) {
+	c.listIssues.Add(1)
+	log.Printf("警告: 跳过目录 %s 中的条目: %v", dir, err)
+}
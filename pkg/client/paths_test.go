@@ -0,0 +1,316 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// specialNames 含有各类特殊字符的文件名，用于往返测试
+var specialNames = []string{
+	"100%.txt",       // 百分号
+	"%41%42.txt",     // 看起来已经编码的名称，不能被解码两次
+	"a%2Fb.txt",      // 编码形式的斜杠作为普通字符
+	"c#1.txt",        // 井号
+	"why?.txt",       // 问号
+	"a+b.txt",        // 加号不能变成空格
+	"with space.txt", // 空格
+	" lead and trail ",
+	"中文 文件.txt", // 非ASCII字符
+	"café.txt",
+	"emoji😀.txt",
+	"semi;colon,comma.txt",
+	"amp&eq=.txt",
+	`quote'"back\slash.txt`,
+	"~tilde_-.txt",
+	".hidden",
+}
+
+// newTestServer 启动一个以 dir 为存储目录、挂载在 prefix 下的WebDAV测试服务器
+func newTestServer(t *testing.T, prefix, dir string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(&webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: webdav.Dir(dir),
+		LockSystem: webdav.NewMemLS(),
+	})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestClient 创建连接到测试服务器的客户端，root 为配置的根路径，可以已经编码
+func newTestClient(t *testing.T, srv *httptest.Server, root string) *WebDAVClient {
+	t.Helper()
+	c, err := NewWebDAVClient(srv.URL+root, Options{Proxy: "none"})
+	if err != nil {
+		t.Fatalf("NewWebDAVClient: %v", err)
+	}
+	return c
+}
+
+func TestEncodePath(t *testing.T) {
+	c, err := NewWebDAVClient("http://example.com/dav/我的 文件/", Options{Proxy: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://example.com/dav/%E6%88%91%E7%9A%84%20%E6%96%87%E4%BB%B6/"
+
+	tests := []struct {
+		in, want string
+	}{
+		{"/", base},
+		{"", base},
+		{"/a.txt", base + "a.txt"},
+		{"/dir/", base + "dir/"},
+		{"//a//b", base + "a/b"},
+		{"/100%.txt", base + "100%25.txt"},
+		{"/a%2Fb", base + "a%252Fb"},
+		{"/c#1?x", base + "c%231%3Fx"},
+		{"/a+b c", base + "a%2Bb%20c"},
+		{"/semi;colon", base + "semi%3Bcolon"},
+		{"/中", base + "%E4%B8%AD"},
+		{"/~tilde_-.txt", base + "~tilde_-.txt"},
+	}
+	for _, tt := range tests {
+		if got := c.encodePath(tt.in); got != tt.want {
+			t.Errorf("encodePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBaseURLKeepsEscapes(t *testing.T) {
+	// 根地址中已经编码的字符不能被再次编码
+	base, names := baseURL("http://example.com/dav/a%20b/")
+	if base != "http://example.com/dav/a%20b/" {
+		t.Errorf("base = %q", base)
+	}
+	if strings.Join(names, "|") != "dav|a b" {
+		t.Errorf("names = %q", names)
+	}
+}
+
+func TestDecodeHref(t *testing.T) {
+	c, err := NewWebDAVClient("http://example.com/dav/", Options{Proxy: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		href string
+		want string
+	}{
+		{"/dav/", "/"},
+		{"/dav", "/"},
+		{"http://example.com/dav/a.txt", "/a.txt"},
+		{"https://other.example.com:8443/dav/a.txt", "/a.txt"},
+		{"/DAV/a.txt", "/a.txt"}, // 根路径大小写不同
+		{"/dav/a%20b/", "/a b"},
+		{"/dav/100%25.txt", "/100%.txt"},
+		{"/dav/%2541.txt", "/%41.txt"},
+		{"/dav/a%252Fb", "/a%2Fb"},
+		{"/dav/a+b", "/a+b"},
+		{"/dav/why?.txt", "/why?.txt"}, // 部分服务器不编码问号和井号
+		{"/dav/c#1.txt", "/c#1.txt"},
+		{"/dav/why%3F%23.txt", "/why?#.txt"},
+		{"/dav/%E4%B8%AD%E6%96%87", "/中文"},
+		{"/dav/中文", "/中文"},
+		{"/dav//a//b/", "/a/b"},
+	}
+	for _, tt := range tests {
+		got, err := c.decodeHref(tt.href)
+		if err != nil || got != tt.want {
+			t.Errorf("decodeHref(%q) = %q, %v, want %q", tt.href, got, err, tt.want)
+		}
+	}
+
+	invalid := []string{
+		"/dav/a%2Fb",              // 编码的斜杠
+		"/dav/a%2fb",              // 小写的编码斜杠
+		"/dav/%FF.txt",            // 无效的UTF-8
+		"/dav/%C3%28",             // 无效的UTF-8序列
+		"/dav/%zz",                // 无效的百分号编码
+		"/dav/100%",               // 不完整的百分号编码
+		"/dav/a%00b",              // 空字符
+		"/dav/..",                 // 上级目录
+		"/dav/%2E%2E/etc",         // 编码的上级目录
+		"/dav/.",                  // 当前目录
+		"/",                       // 根路径之外
+		"/other/a.txt",            // 根路径之外
+		"/davx/a.txt",             // 前缀相同但不是根路径
+		"http://example.com/",     // 根路径之外的完整URL
+		"http://example.com/etc/", // 根路径之外的完整URL
+	}
+	for _, href := range invalid {
+		got, err := c.decodeHref(href)
+		var pe *PathError
+		if !errors.As(err, &pe) {
+			t.Errorf("decodeHref(%q) = %q, %v, want *PathError", href, got, err)
+		}
+	}
+}
+
+func TestDecodeHrefNestedRoot(t *testing.T) {
+	// 根路径本身含有需要编码的字符
+	c, err := NewWebDAVClient("http://example.com/remote.php/dav/files/张 三/", Options{Proxy: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.decodeHref("/remote.php/dav/files/%E5%BC%A0%20%E4%B8%89/a%23b")
+	if err != nil || got != "/a#b" {
+		t.Errorf("decodeHref = %q, %v", got, err)
+	}
+	if _, err := c.decodeHref("/remote.php/dav/files/%E6%9D%8E/a"); err == nil {
+		t.Error("其他用户的路径应该被拒绝")
+	}
+}
+
+// TestRoundTrip 上传、列出、查询和下载含有特殊字符的文件和目录，服务器上的名称必须与原名称完全一致
+func TestRoundTrip(t *testing.T) {
+	roots := []struct {
+		prefix string // 服务器上解码后的挂载路径
+		url    string // 配置的根路径
+	}{
+		{"/dav", "/dav"},
+		{"/dav/根 目录", "/dav/根 目录"},                 // 未编码的空格和中文
+		{"/dav/根 #1%", "/dav/%E6%A0%B9%20%231%25"}, // 已经编码的根路径
+	}
+	for _, root := range roots {
+		t.Run(root.url, func(t *testing.T) {
+			store := t.TempDir()
+			srv := newTestServer(t, root.prefix, store)
+			c := newTestClient(t, srv, root.url)
+
+			local := t.TempDir()
+			modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			dir := "/目录 #1%?+"
+			var want []string
+			for i, name := range specialNames {
+				src := filepath.Join(local, "src"+string(rune('a'+i)))
+				content := "content of " + name
+				if err := os.WriteFile(src, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				remote := path.Join(dir, name)
+				if _, err := c.UploadFile(src, remote, modTime); err != nil {
+					t.Fatalf("UploadFile(%q): %v", remote, err)
+				}
+				want = append(want, remote)
+
+				// 服务器上保存的名称与原名称相同
+				if _, err := os.Stat(filepath.Join(store, filepath.FromSlash(remote))); err != nil {
+					t.Errorf("服务器上找不到 %q: %v", remote, err)
+				}
+
+				info, err := c.Stat(remote)
+				if err != nil {
+					t.Errorf("Stat(%q): %v", remote, err)
+				} else if info.Path != remote || info.IsDir || info.Size != int64(len(content)) {
+					t.Errorf("Stat(%q) = %+v", remote, info)
+				}
+
+				dst := filepath.Join(local, "dst"+string(rune('a'+i)))
+				if err := c.DownloadFile(remote, dst, modTime); err != nil {
+					t.Errorf("DownloadFile(%q): %v", remote, err)
+				} else if data, _ := os.ReadFile(dst); string(data) != content {
+					t.Errorf("DownloadFile(%q) 内容为 %q", remote, data)
+				}
+			}
+
+			root, err := c.ListFiles("/")
+			if err != nil {
+				t.Fatalf("ListFiles(/): %v", err)
+			}
+			if len(root) != 1 || root[0].Path != dir || !root[0].IsDir {
+				t.Errorf("ListFiles(/) = %+v", root)
+			}
+
+			files, err := c.ListFiles(dir)
+			if err != nil {
+				t.Fatalf("ListFiles(%q): %v", dir, err)
+			}
+			var got []string
+			for _, f := range files {
+				got = append(got, f.Path)
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("ListFiles(%q) = %q, want %q", dir, got, want)
+			}
+			if n := c.ListIssues(); n != 0 {
+				t.Errorf("ListIssues = %d, want 0", n)
+			}
+		})
+	}
+}
+
+// TestListSkipsUnrepresentableNames 服务器上名称不是有效UTF-8的条目被跳过，并计入 ListIssues
+func TestListSkipsUnrepresentableNames(t *testing.T) {
+	store := t.TempDir()
+	if err := os.WriteFile(filepath.Join(store, "bad\xff.txt"), nil, 0644); err != nil {
+		t.Skipf("文件系统不支持无效UTF-8文件名: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(store, "good.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestServer(t, "/dav", store)
+	c := newTestClient(t, srv, "/dav")
+
+	files, err := c.ListFiles("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "/good.txt" {
+		t.Errorf("ListFiles = %+v", files)
+	}
+	if n := c.ListIssues(); n != 1 {
+		t.Errorf("ListIssues = %d, want 1", n)
+	}
+}
+
+// TestListSkipsForeignHrefs 服务器返回根路径之外、含编码斜杠或不是直接子条目的 href 时跳过这些条目
+func TestListSkipsForeignHrefs(t *testing.T) {
+	const body = `<?xml version="1.0" encoding="utf-8"?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response><D:href>/dav/dir/</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+  <D:response><D:href>/dav/dir/ok%23.txt</D:href><D:propstat><D:prop><D:getcontentlength>3</D:getcontentlength><D:resourcetype/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+  <D:response><D:href>/etc/passwd</D:href><D:propstat><D:prop><D:resourcetype/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+  <D:response><D:href>http://evil.example.com/other/x</D:href><D:propstat><D:prop><D:resourcetype/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+  <D:response><D:href>/dav/dir/a%2Fb</D:href><D:propstat><D:prop><D:resourcetype/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+  <D:response><D:href>/dav/dir/%2E%2E/%2E%2E/x</D:href><D:propstat><D:prop><D:resourcetype/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+  <D:response><D:href>/dav/dir/sub/deep.txt</D:href><D:propstat><D:prop><D:resourcetype/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>
+</D:multistatus>`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" || r.URL.EscapedPath() != "/dav/dir/" {
+			http.NotFound(w, r)
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv, "/dav")
+
+	files, err := c.ListFiles("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "/dir/ok#.txt" || files[0].Size != 3 {
+		t.Errorf("ListFiles = %+v", files)
+	}
+	if n := c.ListIssues(); n != 5 {
+		t.Errorf("ListIssues = %d, want 5", n)
+	}
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// propfindBasicBody 请求同步所需基本属性的 PROPFIND 请求体
+const propfindBasicBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+	<d:prop>
+		<d:resourcetype/>
+		<d:getcontentlength/>
+		<d:getlastmodified/>
+	</d:prop>
+</d:propfind>`
+
+// propfindAllBody 请求全部属性的 PROPFIND 请求体
+const propfindAllBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+	<d:allprop/>
+</d:propfind>`
+
+// Property 服务器返回的一个 WebDAV 属性
+type Property struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Value     string `json:"value"` // 属性的原始XML内容，如 <d:collection/>
+}
+
+// davMultistatus 207 Multi-Status 响应
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+// davResponse 多状态响应中的单个资源
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Status    string        `xml:"DAV: status"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+// davPropstat 一组具有相同状态的属性
+type davPropstat struct {
+	Status string  `xml:"DAV: status"`
+	Prop   davProp `xml:"DAV: prop"`
+}
+
+// davProp 属性集合，保留每个属性的原始内容
+type davProp struct {
+	Props []struct {
+		XMLName xml.Name
+		Inner   string `xml:",innerxml"`
+	} `xml:",any"`
+}
+
+// okProps 返回状态为 200 的属性，键为 "命名空间 本地名"
+func (r *davResponse) okProps() map[string]string {
+	props := make(map[string]string)
+	for _, ps := range r.Propstats {
+		if !strings.Contains(ps.Status, " 200") {
+			continue
+		}
+		for _, p := range ps.Prop.Props {
+			props[p.XMLName.Space+" "+p.XMLName.Local] = strings.TrimSpace(p.Inner)
+		}
+	}
+	return props
+}
+
+// fileInfo 将响应转换为 FileInfo
+func (r *davResponse) fileInfo(remotePath string) FileInfo {
+	props := r.okProps()
+	info := FileInfo{
+		Path:  remotePath,
+		IsDir: strings.Contains(props["DAV: resourcetype"], "collection"),
+	}
+	if !info.IsDir {
+		info.Size, _ = strconv.ParseInt(props["DAV: getcontentlength"], 10, 64)
+	}
+	if t, err := http.ParseTime(props["DAV: getlastmodified"]); err == nil {
+		info.LastModified = t
+	}
+	return info
+}
+
+// propfind 发送 PROPFIND 请求并解析多状态响应
+func (c *WebDAVClient) propfind(remotePath, depth, body string) ([]davResponse, error) {
+	resp, err := c.request("PROPFIND", remotePath, strings.NewReader(body), func(req *http.Request) {
+		req.Header.Set("Depth", depth)
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+		req.Header.Set("Accept", "application/xml,text/xml")
+	})
+	if err != nil {
+		return nil, wrapError("PROPFIND", remotePath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, newStatusError("PROPFIND", remotePath, resp)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: 解析响应失败: %v", remotePath, err)
+	}
+	return ms.Responses, nil
+}
+
+// ListTree 使用 Depth: infinity 一次性列出远程目录下的所有文件和目录
+// 仅在服务器支持无限深度 PROPFIND 时可用
+func (c *WebDAVClient) ListTree(remotePath string) ([]FileInfo, error) {
+	responses, err := c.propfind(remotePath, "infinity", propfindBasicBody)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录树 %s 失败: %w", remotePath, err)
+	}
+
+	self := "/" + strings.Trim(remotePath, "/")
+	var result []FileInfo
+	for i := range responses {
+		p, err := c.decodeHref(responses[i].Href)
+		if err != nil {
+			c.reportPath(self, err)
+			continue
+		}
+		if p == self {
+			continue
+		}
+		result = append(result, responses[i].fileInfo(p))
+	}
+	return result, nil
+}
+
+// AllProperties 通过 Depth: 0 的 allprop PROPFIND 读取远程文件或目录的全部属性，按服务器返回的顺序排列
+// 只包含状态为 200 的属性；部分服务器不在 allprop 中返回自定义属性
+func (c *WebDAVClient) AllProperties(remotePath string) ([]Property, error) {
+	responses, err := c.propfind(remotePath, "0", propfindAllBody)
+	if err != nil {
+		return nil, fmt.Errorf("读取属性 %s 失败: %w", remotePath, err)
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("读取属性 %s 失败: 服务器返回了空的多状态响应", remotePath)
+	}
+
+	var props []Property
+	for _, ps := range responses[0].Propstats {
+		if !strings.Contains(ps.Status, " 200") {
+			continue
+		}
+		for _, p := range ps.Prop.Props {
+			props = append(props, Property{Namespace: p.XMLName.Space, Name: p.XMLName.Local, Value: strings.TrimSpace(p.Inner)})
+		}
+	}
+	return props, nil
+}
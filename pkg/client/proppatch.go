@@ -0,0 +1,85 @@
+package client
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// propPatchMtimeBody 设置 getlastmodified 属性的 PROPPATCH 请求体
+const propPatchMtimeBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propertyupdate xmlns:d="DAV:">
+	<d:set>
+		<d:prop>
+			<d:getlastmodified>%s</d:getlastmodified>
+		</d:prop>
+	</d:set>
+</d:propertyupdate>`
+
+// errModTimeUnsupported 服务器明确不允许通过 PROPPATCH 修改 getlastmodified
+var errModTimeUnsupported = errors.New("服务器不支持修改 getlastmodified")
+
+// SetModTime 通过 PROPPATCH 设置远程文件的修改时间，返回是否设置成功
+// 大多数服务器将 getlastmodified 视为受保护属性，确认服务器不支持后不再对该服务器尝试；
+// 超时、5xx 等临时错误只影响本次调用
+func (c *WebDAVClient) SetModTime(remotePath string, modTime time.Time) bool {
+	if c.noPropPatch.Load() {
+		return false
+	}
+
+	if err := c.propPatchModTime(remotePath, modTime); err != nil {
+		if errors.Is(err, errModTimeUnsupported) {
+			c.noPropPatch.Store(true)
+		}
+		return false
+	}
+
+	// 部分服务器接受请求但只把它当作普通属性保存，需确认实际生效
+	info, err := c.Stat(remotePath)
+	if err != nil {
+		return false
+	}
+	if info.LastModified.Sub(modTime.Truncate(time.Second)).Abs() >= time.Second {
+		c.noPropPatch.Store(true)
+		return false
+	}
+	return true
+}
+
+// propPatchModTime 发送设置 getlastmodified 的 PROPPATCH 请求
+// 服务器明确拒绝（403、405、409、422、501 或属性状态不是 200）时返回的错误包含 errModTimeUnsupported
+func (c *WebDAVClient) propPatchModTime(remotePath string, modTime time.Time) error {
+	body := fmt.Sprintf(propPatchMtimeBody, modTime.UTC().Format(http.TimeFormat))
+	resp, err := c.request("PROPPATCH", remotePath, strings.NewReader(body), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	})
+	if err != nil {
+		return wrapError("PROPPATCH", remotePath, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusConflict,
+		http.StatusUnprocessableEntity, http.StatusNotImplemented:
+		return fmt.Errorf("%w: %w", errModTimeUnsupported, newStatusError("PROPPATCH", remotePath, resp))
+	default:
+		return newStatusError("PROPPATCH", remotePath, resp)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return fmt.Errorf("PROPPATCH %s: 解析响应失败: %v", remotePath, err)
+	}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200") {
+				return fmt.Errorf("%w: PROPPATCH %s: %s", errModTimeUnsupported, remotePath, ps.Status)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSetModTimeLatch 只有服务器明确不支持时才停止尝试 PROPPATCH，临时错误只影响本次调用
+func TestSetModTimeLatch(t *testing.T) {
+	const failedPropstat = `<?xml version="1.0" encoding="utf-8"?>
+<D:multistatus xmlns:D="DAV:"><D:response><D:href>/dav/a</D:href>
+<D:propstat><D:prop><D:getlastmodified/></D:prop><D:status>HTTP/1.1 403 Forbidden</D:status></D:propstat>
+</D:response></D:multistatus>`
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		latch  bool
+	}{
+		{"server error", http.StatusInternalServerError, "", false},
+		{"bad gateway", http.StatusBadGateway, "", false},
+		{"rate limited", http.StatusTooManyRequests, "", false},
+		{"not found", http.StatusNotFound, "", false},
+		{"forbidden", http.StatusForbidden, "", true},
+		{"method not allowed", http.StatusMethodNotAllowed, "", true},
+		{"conflict", http.StatusConflict, "", true},
+		{"unprocessable", http.StatusUnprocessableEntity, "", true},
+		{"not implemented", http.StatusNotImplemented, "", true},
+		{"failed propstat", http.StatusMultiStatus, failedPropstat, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+			c := newTestClient(t, srv, "/dav")
+
+			if c.SetModTime("/a", time.Now()) {
+				t.Fatal("SetModTime 应该失败")
+			}
+			if got := c.noPropPatch.Load(); got != tt.latch {
+				t.Errorf("noPropPatch = %v, want %v", got, tt.latch)
+			}
+		})
+	}
+}
+
+// TestSetModTimeIgnored 服务器接受请求但修改时间没有生效时停止尝试
+func TestSetModTimeIgnored(t *testing.T) {
+	const ok = `<?xml version="1.0" encoding="utf-8"?>
+<D:multistatus xmlns:D="DAV:"><D:response><D:href>/dav/a</D:href>
+<D:propstat><D:prop><D:getlastmodified/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat>
+</D:response></D:multistatus>`
+	const stat = `<?xml version="1.0" encoding="utf-8"?>
+<D:multistatus xmlns:D="DAV:"><D:response><D:href>/dav/a</D:href>
+<D:propstat><D:prop><D:getlastmodified>Mon, 01 Jan 2024 00:00:00 GMT</D:getlastmodified><D:resourcetype/></D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat>
+</D:response></D:multistatus>`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusMultiStatus)
+		if r.Method == "PROPPATCH" {
+			io.WriteString(w, ok)
+		} else {
+			io.WriteString(w, stat)
+		}
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv, "/dav")
+
+	if !c.SetModTime("/a", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("修改时间与服务器返回的一致时应该成功")
+	}
+	if c.noPropPatch.Load() {
+		t.Fatal("成功后不应停止尝试")
+	}
+	if c.SetModTime("/a", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("修改时间没有生效时应该失败")
+	}
+	if !c.noPropPatch.Load() {
+		t.Error("修改时间没有生效时应停止尝试")
+	}
+}
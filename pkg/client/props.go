@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// PropNamespace 本程序保存的自定义属性（dead property）使用的XML命名空间
+const PropNamespace = "urn:sync-using-webdav"
+
+// propPatchSetBody 设置自定义属性的 PROPPATCH 请求体
+const propPatchSetBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propertyupdate xmlns:d="DAV:" xmlns:s="%s">
+	<d:set>
+		<d:prop>
+			<s:%s>%s</s:%s>
+		</d:prop>
+	</d:set>
+</d:propertyupdate>`
+
+// propfindPropBody 读取自定义属性的 PROPFIND 请求体
+const propfindPropBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:s="%s">
+	<d:prop>
+		<s:%s/>
+	</d:prop>
+</d:propfind>`
+
+// SetProperty 通过 PROPPATCH 在远程文件或目录上保存自定义属性
+// 服务器拒绝保存属性时返回的错误可以用 errors.Is(err, ErrPropsUnsupported) 判断
+func (c *WebDAVClient) SetProperty(remotePath, name, value string) error {
+	var escaped bytes.Buffer
+	if err := xml.EscapeText(&escaped, []byte(value)); err != nil {
+		return err
+	}
+	body := fmt.Sprintf(propPatchSetBody, PropNamespace, name, escaped.String(), name)
+	resp, err := c.request("PROPPATCH", remotePath, strings.NewReader(body), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	})
+	if err != nil {
+		return wrapError("PROPPATCH", remotePath, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented, http.StatusForbidden:
+		return fmt.Errorf("%w: %w", ErrPropsUnsupported, newStatusError("PROPPATCH", remotePath, resp))
+	default:
+		return newStatusError("PROPPATCH", remotePath, resp)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return fmt.Errorf("PROPPATCH %s: 解析响应失败: %v", remotePath, err)
+	}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200") {
+				return fmt.Errorf("%w: PROPPATCH %s: %s", ErrPropsUnsupported, remotePath, ps.Status)
+			}
+		}
+	}
+	return nil
+}
+
+// Properties 通过 Depth: 1 的 PROPFIND 读取远程目录本身及其直接子条目的自定义属性
+// 返回以 / 开头的路径到属性值的映射，没有该属性的条目不在结果中
+func (c *WebDAVClient) Properties(remotePath, name string) (map[string]string, error) {
+	responses, err := c.propfind(remotePath, "1", fmt.Sprintf(propfindPropBody, PropNamespace, name))
+	if err != nil {
+		return nil, fmt.Errorf("读取属性 %s 失败: %w", remotePath, err)
+	}
+
+	result := make(map[string]string)
+	for i := range responses {
+		raw, ok := responses[i].okProps()[PropNamespace+" "+name]
+		if !ok {
+			continue
+		}
+		p, err := c.decodeHref(responses[i].Href)
+		if err != nil {
+			log.Printf("警告: 跳过 %s 中条目的属性: %v", remotePath, err)
+			continue
+		}
+		value, err := unescapeXML(raw)
+		if err != nil {
+			return nil, fmt.Errorf("解析属性 %s 失败: %v", p, err)
+		}
+		result[p] = value
+	}
+	return result, nil
+}
+
+// unescapeXML 还原属性原始内容中的XML转义字符
+func unescapeXML(raw string) (string, error) {
+	var v struct {
+		Text string `xml:",chardata"`
+	}
+	if err := xml.Unmarshal([]byte("<v>"+raw+"</v>"), &v); err != nil {
+		return "", err
+	}
+	return v.Text, nil
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"io"
+	"time"
+)
+
+// Remote 同步过程使用的远程存储操作
+// WebDAVClient 直接实现该接口，加密等功能以包装 Remote 的形式叠加在其上
+type Remote interface {
+	ListFiles(remotePath string) ([]FileInfo, error)
+	ListTree(remotePath string) ([]FileInfo, error)
+	Stat(remotePath string) (FileInfo, error)
+	FileExists(remotePath string) (bool, error)
+	MakeDir(remotePath string) error
+	ReadStream(remotePath string) (io.ReadCloser, error)
+	DownloadFile(remotePath, localPath string, modTime time.Time) error
+	UploadFile(localPath, remotePath string, modTime time.Time) (UploadResult, error)
+	UploadStream(remotePath string, reader io.Reader, size int64, modTime time.Time) (UploadResult, error)
+	Move(oldPath, newPath string, overwrite bool) error
+	Copy(oldPath, newPath string, overwrite bool) error
+	RemoveRemote(remotePath string) error
+	RemoveRemoteAll(remotePath string) error
+	Capabilities() *Capabilities
+
+	// SetModTime 尝试设置远程文件或目录的修改时间，返回是否设置成功；用于在子条目写入后设置目录的修改时间
+	SetModTime(remotePath string, modTime time.Time) bool
+
+	// SetProperty 和 Properties 读写自定义属性（dead property），用于保存文件元数据
+	SetProperty(remotePath, name, value string) error
+	Properties(remotePath, name string) (map[string]string, error)
+
+	// AllProperties 读取远程文件或目录在服务器上的全部属性，用于排查同步问题
+	AllProperties(remotePath string) ([]Property, error)
+
+	// ListIssues 返回目前为止列表结果可能不完整的次数（达到服务器列表上限、条目无法解析等）
+	// 同步过程据此判断是否可以安全地执行删除
+	ListIssues() int64
+}
+
+// UploadResult 上传结果
+type UploadResult struct {
+	Size        int64 // 文件在服务器上（以调用方所见的形式）的大小，压缩后的文件为压缩后的大小
+	ModTimeKept bool  // 服务器上的修改时间是否已设置为传入的修改时间
+}
+
+var _ Remote = (*WebDAVClient)(nil)
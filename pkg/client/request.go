@@ -0,0 +1,63 @@
+package client
+
+import (
+	"io"
+	"net/http"
+)
+
+// request 发送WebDAV请求，所有请求都通过 encodePath 编码路径
+// 认证由 c.auth 完成：Basic/Digest 认证或 Bearer 令牌，认证失败需要重新发送时使用缓冲的请求体
+// intercept 在每次发送前调用，用于设置请求头和请求体长度
+func (c *WebDAVClient) request(method, remotePath string, body io.Reader, intercept func(*http.Request)) (*http.Response, error) {
+	uri := c.encodePath(remotePath)
+	auth, body := c.auth.NewAuthenticator(body)
+	defer auth.Close()
+
+	for {
+		req, err := http.NewRequest(method, uri, body)
+		if err != nil {
+			return nil, err
+		}
+		if c.userAgent != "" {
+			req.Header.Set("User-Agent", c.userAgent)
+		}
+		if intercept != nil {
+			intercept(req)
+		}
+
+		if err := auth.Authorize(c.http, req, remotePath); err != nil {
+			return nil, err
+		}
+		// 自定义请求头最后设置，其中的 Authorization 会覆盖认证器设置的值
+		for key, value := range c.headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		redo, err := auth.Verify(c.http, resp, remotePath)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if !redo {
+			return resp, nil
+		}
+
+		// 认证需要额外往返，使用缓冲的请求体重新发送
+		resp.Body.Close()
+		if req.GetBody != nil {
+			if body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// destination 返回 MOVE/COPY 请求中 Destination 头使用的完整URL
+func (c *WebDAVClient) destination(remotePath string) string {
+	return c.encodePath(remotePath)
+}
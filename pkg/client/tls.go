@@ -0,0 +1,70 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// newTLSConfig 根据配置创建TLS设置，没有任何TLS相关配置时返回 nil，使用系统默认设置
+func newTLSConfig(opts Options) (*tls.Config, error) {
+	if opts.ClientCert == "" && opts.CACert == "" && len(opts.PinSHA256) == 0 && !opts.InsecureSkipVerify {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: opts.InsecureSkipVerify}
+
+	// 双向TLS的客户端证书
+	if opts.ClientCert != "" {
+		keyFile := opts.ClientKey
+		if keyFile == "" {
+			keyFile = opts.ClientCert // 证书和私钥保存在同一个PEM文件中
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	// 自定义CA证书，用于私有CA签发的服务器证书
+	if opts.CACert != "" {
+		data, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA证书文件 %s 中没有有效的PEM证书", opts.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	// 固定服务器证书：只接受指纹匹配的证书，不再验证证书链，适用于自签名证书
+	if len(opts.PinSHA256) > 0 {
+		pins := make(map[string]bool)
+		for _, pin := range opts.PinSHA256 {
+			pin = strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(pin, "sha256:"), ":", ""))
+			if b, err := hex.DecodeString(pin); err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("无效的证书指纹: %s (应为64位十六进制SHA-256指纹)", pin)
+			}
+			pins[pin] = true
+		}
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("服务器没有提供证书")
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+			if !pins[hex.EncodeToString(sum[:])] {
+				return fmt.Errorf("服务器证书指纹 %s 与配置的 tls_pin_sha256 不匹配", hex.EncodeToString(sum[:]))
+			}
+			return nil
+		}
+	}
+	return cfg, nil
+}
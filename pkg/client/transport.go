@@ -0,0 +1,102 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttledTransport 在请求之间保持最小间隔的 RoundTripper，用于有请求频率限制的服务器
+type throttledTransport struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	interval time.Duration
+	last     time.Time
+}
+
+// setInterval 设置两次请求之间的最小间隔，0 表示不限制
+func (t *throttledTransport) setInterval(interval time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interval = interval
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if t.interval > 0 {
+		if wait := time.Until(t.last.Add(t.interval)); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	t.last = time.Now()
+	t.mu.Unlock()
+
+	return t.next.RoundTrip(req)
+}
+
+// newHTTPTransport 根据配置创建底层的 http.Transport
+func newHTTPTransport(opts Options) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := proxyFunc(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	maxConns := opts.MaxConns
+	if maxConns <= 0 {
+		maxConns = 10
+	}
+
+	dialer := &net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          maxConns,
+		MaxIdleConnsPerHost:   maxConns,
+		ForceAttemptHTTP2:     opts.HTTP2,
+	}
+	if !opts.HTTP2 {
+		// 非 nil 的空映射会禁止通过 ALPN 协商 HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport, nil
+}
+
+// proxyFunc 解析代理配置：为空时读取 HTTP_PROXY、HTTPS_PROXY 和 NO_PROXY 环境变量，none 或 direct 表示直接连接
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	switch strings.ToLower(proxy) {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "none", "direct":
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("无效的代理地址 %s: %w", proxy, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理类型 %s (可选 http, https, socks5)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("无效的代理地址 %s: 缺少主机名", proxy)
+	}
+	return http.ProxyURL(u), nil
+}
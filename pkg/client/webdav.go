@@ -1,12 +1,16 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/studio-b12/gowebdav"
@@ -22,169 +26,223 @@ type FileInfo struct {
 
 // WebDAVClient WebDAV客户端封装
 type WebDAVClient struct {
-	client *gowebdav.Client
+	// noPropPatch 服务器不允许通过 PROPPATCH 修改 getlastmodified 时置为 true，之后不再尝试
+	noPropPatch atomic.Bool
+
+	root      string   // 配置的WebDAV地址，用于显示和推断服务器类型
+	base      string   // 编码后的根地址前缀，以 / 结尾
+	rootNames []string // 解码后的根路径各级名称，用于从 href 中去掉根路径
+	auth      gowebdav.Authorizer
+	headers   map[string]string // 每个请求附加的请求头
+	userAgent string
+	http      *http.Client
+	transport *throttledTransport
+
+	caps *Capabilities // 探测到的服务器能力，调用 Probe 前为 nil
+
+	listIssues atomic.Int64 // 可能不完整的目录列表次数
+}
+
+// Options WebDAV客户端配置
+type Options struct {
+	Username string
+	Password string
+
+	// BearerToken 不为空或设置了 RefreshToken 时使用 Bearer 令牌认证，不再使用用户名和密码
+	BearerToken  string
+	RefreshToken func() (string, error) // 获取新令牌，服务器返回 401 时调用，可以为 nil
+
+	Headers map[string]string // 每个请求都附加的请求头，如自定义认证头
+
+	ClientCert string   // 双向TLS的客户端证书（PEM）
+	ClientKey  string   // 客户端证书的私钥（PEM），为空时从 ClientCert 中读取
+	CACert     string   // 验证服务器证书使用的CA证书（PEM），为空时使用系统CA
+	PinSHA256  []string // 服务器证书的SHA-256指纹，设置后只接受指纹匹配的证书
+
+	// InsecureSkipVerify 不验证服务器证书，仅用于测试环境
+	InsecureSkipVerify bool
+
+	Proxy    string // 代理地址，支持 http://、https:// 和 socks5://；为空时使用环境变量，"none" 表示不使用代理
+	MaxConns int    // 连接池中每个主机保留的空闲连接数，通常等于最大并发数
+
+	ConnectTimeout        time.Duration // 建立TCP连接的超时时间，0 表示不限制
+	TLSHandshakeTimeout   time.Duration // TLS握手的超时时间，0 表示不限制
+	ResponseHeaderTimeout time.Duration // 发送请求后等待响应头的超时时间，0 表示不限制
+	IdleConnTimeout       time.Duration // 空闲连接保留的时间，0 表示不限制
+
+	HTTP2     bool   // 是否允许使用 HTTP/2
+	UserAgent string // User-Agent 请求头，为空时使用Go的默认值
 }
 
 // NewWebDAVClient 创建新的WebDAV客户端
-func NewWebDAVClient(url, username, password string) *WebDAVClient {
-	// 创建WebDAV客户端
-	davClient := gowebdav.NewClient(url, username, password)
-
-	// 配置客户端
-	transport := &http.Transport{
-		MaxIdleConns:        10,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     30 * time.Second,
+func NewWebDAVClient(url string, opts Options) (*WebDAVClient, error) {
+	// 默认使用gowebdav的认证器，支持Basic和Digest认证
+	var auth gowebdav.Authorizer
+	if opts.BearerToken != "" || opts.RefreshToken != nil {
+		tokenAuth, err := newTokenAuthorizer(opts.BearerToken, opts.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+		auth = tokenAuth
+	} else {
+		auth = gowebdav.NewAutoAuth(opts.Username, opts.Password)
 	}
-	davClient.SetTransport(transport)
 
-	return &WebDAVClient{
-		client: davClient,
+	next, err := newHTTPTransport(opts)
+	if err != nil {
+		return nil, err
 	}
+
+	// 配置客户端，所有请求经过同一个可限速的传输层
+	transport := &throttledTransport{next: next}
+
+	base, rootNames := baseURL(url)
+	return &WebDAVClient{
+		root:      gowebdav.FixSlash(url),
+		base:      base,
+		rootNames: rootNames,
+		auth:      auth,
+		headers:   opts.Headers,
+		userAgent: opts.UserAgent,
+		http:      &http.Client{Transport: transport},
+		transport: transport,
+	}, nil
 }
 
 // ListFiles 列出远程目录中的所有文件
+// 服务器返回的名称无法表示时跳过该条目并给出警告，同时计入 ListIssues
 func (c *WebDAVClient) ListFiles(remotePath string) ([]FileInfo, error) {
-	files, err := c.listRemoteFiles(remotePath)
+	self := "/" + strings.Trim(remotePath, "/")
+
+	// 目录地址以 / 结尾，避免部分服务器重定向
+	responses, err := c.propfind(strings.TrimSuffix(self, "/")+"/", "1", propfindBasicBody)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("读取目录 %s 失败: %w", self, err)
 	}
 
 	var result []FileInfo
-	for _, file := range files {
-		// 跳过当前目录和父目录引用
-		path := file.Path
-		name := filepath.Base(path)
-		if name == "." || name == ".." {
+	for i := range responses {
+		p, err := c.decodeHref(responses[i].Href)
+		if err != nil {
+			c.reportPath(self, err)
+			continue
+		}
+		// 目录自身按路径识别，不依赖它在响应中的位置
+		if p == self {
 			continue
 		}
-		result = append(result, file)
+		if path.Dir(p) != self {
+			c.reportPath(self, &PathError{Href: responses[i].Href, Reason: "不是所列目录的直接子条目"})
+			continue
+		}
+		result = append(result, responses[i].fileInfo(p))
+	}
+
+	if c.caps != nil && c.caps.Profile.ListLimit > 0 && len(responses)-1 >= c.caps.Profile.ListLimit {
+		c.listIssues.Add(1)
+		log.Printf("警告: 目录 %s 返回了 %d 个条目，达到服务器 %s 的列表上限，结果可能不完整",
+			self, len(responses)-1, c.caps.Profile.Name)
 	}
 	return result, nil
 }
 
-// listRemoteFiles 列出远程目录中的所有文件的实现
-func (c *WebDAVClient) listRemoteFiles(remotePath string) ([]FileInfo, error) {
-	// 确保路径以 / 开始
-	if !strings.HasPrefix(remotePath, "/") {
-		remotePath = "/" + remotePath
-	}
+// ListIssues 返回目前为止可能不完整的目录列表次数
+func (c *WebDAVClient) ListIssues() int64 {
+	return c.listIssues.Load()
+}
 
-	// 处理根路径的特殊情况
-	if remotePath == "/" {
-		remotePath = ""
+// Stat 获取远程文件或目录的信息
+func (c *WebDAVClient) Stat(remotePath string) (FileInfo, error) {
+	responses, err := c.propfind(remotePath, "0", propfindBasicBody)
+	if err == nil && len(responses) == 0 {
+		err = &Error{Method: "PROPFIND", Path: remotePath, Err: errors.New("响应中没有条目")}
 	}
-
-	files, err := c.client.ReadDir(remotePath)
 	if err != nil {
-		return nil, fmt.Errorf("读取目录 %s 失败: %v", remotePath, err)
-	}
-
-	var result []FileInfo
-	for _, file := range files {
-		// 构建完整路径
-		path := remotePath
-		if path != "" && !strings.HasSuffix(path, "/") {
-			path += "/"
-		}
-		path += file.Name()
-
-		result = append(result, FileInfo{
-			Path:         path,
-			IsDir:        file.IsDir(),
-			LastModified: file.ModTime(),
-			Size:         file.Size(),
-		})
+		return FileInfo{}, fmt.Errorf("获取远程文件信息 %s 失败: %w", remotePath, err)
 	}
-
-	return result, nil
+	return responses[0].fileInfo(remotePath), nil
 }
 
 // ReadStream 获取远程文件的读取流
 func (c *WebDAVClient) ReadStream(remotePath string) (io.ReadCloser, error) {
-	return c.client.ReadStream(remotePath)
+	resp, err := c.request("GET", remotePath, nil, nil)
+	if err != nil {
+		return nil, wrapError("GET", remotePath, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, newStatusError("GET", remotePath, resp)
+	}
+	return resp.Body, nil
 }
 
 // DownloadFile 下载文件到指定本地路径
 func (c *WebDAVClient) DownloadFile(remotePath, localPath string, remoteModTime time.Time) error {
-	// 先获取文件信息以了解文件大小
-	_, err := c.client.Stat(remotePath)
-	if err != nil {
-		return fmt.Errorf("获取远程文件信息失败: %v", err)
-	}
-
 	// 读取远程文件
 	reader, err := c.ReadStream(remotePath)
 	if err != nil {
-		return fmt.Errorf("读取远程文件失败: %v", err)
+		return fmt.Errorf("读取远程文件失败: %w", err)
 	}
 	defer reader.Close()
 
-	// 创建临时文件
-	tmpFile := localPath + ".download"
-	file, err := os.Create(tmpFile)
-	if err != nil {
-		return err
-	}
-
-	// 确保在函数返回前关闭并删除临时文件（如果出错）
-	defer func() {
-		file.Close()
-		if err != nil {
-			os.Remove(tmpFile)
-		}
-	}()
-
-	// 直接复制文件
-	_, err = io.Copy(file, reader)
-	if err != nil {
-		return err
-	}
-
-	// 关闭文件
-	if err = file.Close(); err != nil {
-		return err
-	}
-
-	// 原子性地替换文件
-	if err = os.Rename(tmpFile, localPath); err != nil {
-		return err
-	}
-
-	// 设置文件修改时间与远程文件一致
-	return os.Chtimes(localPath, remoteModTime, remoteModTime)
+	return WriteLocalFile(localPath, reader, remoteModTime)
 }
 
-// UploadFile 上传本地文件到WebDAV服务器
-func (c *WebDAVClient) UploadFile(localPath, remotePath string, localModTime time.Time) error {
+// UploadFile 上传本地文件到WebDAV服务器，并尽量保留文件的修改时间
+// 返回结果中 ModTimeKept 为 false 时调用方需要自行记录本地修改时间
+func (c *WebDAVClient) UploadFile(localPath, remotePath string, localModTime time.Time) (UploadResult, error) {
 	// 获取本地文件信息
-	_, err := os.Stat(localPath)
+	info, err := os.Stat(localPath)
 	if err != nil {
-		return fmt.Errorf("获取本地文件信息失败: %v", err)
+		return UploadResult{}, fmt.Errorf("获取本地文件信息失败: %w", err)
 	}
 
 	// 打开本地文件
 	file, err := os.Open(localPath)
 	if err != nil {
-		return fmt.Errorf("打开本地文件失败: %v", err)
+		return UploadResult{}, fmt.Errorf("打开本地文件失败: %w", err)
 	}
 	defer file.Close()
 
+	return c.UploadStream(remotePath, file, info.Size(), localModTime)
+}
+
+// UploadStream 将数据流上传到WebDAV服务器，size 为数据流的确切长度
+// 返回结果含义与 UploadFile 相同
+func (c *WebDAVClient) UploadStream(remotePath string, reader io.Reader, size int64, modTime time.Time) (UploadResult, error) {
 	// 确保远程目录存在
-	remoteDir := filepath.Dir(remotePath)
+	remoteDir := path.Dir(remotePath)
 	if remoteDir != "." && remoteDir != "/" {
 		if err := c.MakeDir(remoteDir); err != nil {
-			return fmt.Errorf("创建远程目录失败: %v", err)
+			return UploadResult{}, fmt.Errorf("创建远程目录失败: %w", err)
 		}
 	}
 
-	// 上传文件
-	err = c.client.WriteStream(remotePath, file, 0644)
+	// 上传文件，同时通过 X-OC-Mtime 请求头告知 Nextcloud/ownCloud 文件的修改时间
+	resp, err := c.request("PUT", remotePath, reader, func(req *http.Request) {
+		req.ContentLength = size
+		req.Header.Set("X-OC-Mtime", strconv.FormatInt(modTime.Unix(), 10))
+	})
 	if err != nil {
-		return fmt.Errorf("上传文件失败: %v", err)
+		return UploadResult{}, fmt.Errorf("上传文件失败: %w", wrapError("PUT", remotePath, err))
 	}
+	resp.Body.Close()
 
-	return nil
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	default:
+		return UploadResult{}, fmt.Errorf("上传文件失败: %w", newStatusError("PUT", remotePath, resp))
+	}
+
+	result := UploadResult{Size: size}
+	if strings.EqualFold(resp.Header.Get("X-OC-MTime"), "accepted") {
+		result.ModTimeKept = true
+		return result, nil
+	}
+
+	// 服务器不支持 X-OC-Mtime 时尝试 PROPPATCH
+	result.ModTimeKept = c.SetModTime(remotePath, modTime)
+	return result, nil
 }
 
 // MakeDir 在远程创建目录（包括多级目录）
@@ -195,23 +253,20 @@ func (c *WebDAVClient) MakeDir(remotePath string) error {
 		return nil // 根目录不需要创建
 	}
 
-	parts := strings.Split(remotePath, "/")
+	parts := strings.Split(strings.TrimSuffix(remotePath, "/"), "/")
 	current := ""
 
 	// 递归创建目录
 	for _, part := range parts {
-		if current != "" {
-			current += "/"
-		}
-		current += part
+		current += "/" + part
 
 		// 尝试创建目录（如果已存在则忽略错误）
-		err := c.client.Mkdir(current, 0755)
+		err := c.mkcol(current)
 		if err != nil {
 			// 检查目录是否已存在
-			info, statErr := c.client.Stat(current)
-			if statErr != nil || !info.IsDir() {
-				return fmt.Errorf("创建目录 %s 失败: %v", current, err)
+			info, statErr := c.Stat(current)
+			if statErr != nil || !info.IsDir {
+				return fmt.Errorf("创建目录 %s 失败: %w", current, err)
 			}
 		}
 	}
@@ -219,25 +274,51 @@ func (c *WebDAVClient) MakeDir(remotePath string) error {
 	return nil
 }
 
+// mkcol 创建单级目录
+func (c *WebDAVClient) mkcol(remotePath string) error {
+	resp, err := c.request("MKCOL", remotePath+"/", nil, nil)
+	if err != nil {
+		return wrapError("MKCOL", remotePath, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return newStatusError("MKCOL", remotePath, resp)
+	}
+	return nil
+}
+
 // RemoveRemote 删除远程文件或目录
+// 路径不存在时视为删除成功
 func (c *WebDAVClient) RemoveRemote(remotePath string) error {
-	return c.client.Remove(remotePath)
+	resp, err := c.request("DELETE", remotePath, nil, nil)
+	if err != nil {
+		return wrapError("DELETE", remotePath, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusAccepted, http.StatusNotFound:
+		return nil
+	}
+	return newStatusError("DELETE", remotePath, resp)
 }
 
 // RemoveRemoteAll 递归删除远程目录及其内容
 func (c *WebDAVClient) RemoveRemoteAll(remotePath string) error {
 	// 先检查是否存在
-	info, err := c.client.Stat(remotePath)
+	info, err := c.Stat(remotePath)
 	if err != nil {
 		// 如果路径本身就不存在，视为删除成功
-		return nil
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
 	}
 
 	// 如果是目录，先删除其中的内容
-	if info.IsDir() {
+	if info.IsDir {
 		files, err := c.ListFiles(remotePath)
 		if err != nil {
-			return fmt.Errorf("列出远程目录失败: %v", err)
+			return fmt.Errorf("列出远程目录失败: %w", err)
 		}
 
 		for _, file := range files {
@@ -248,14 +329,67 @@ func (c *WebDAVClient) RemoveRemoteAll(remotePath string) error {
 	}
 
 	// 最后删除自身
-	return c.client.Remove(remotePath)
+	return c.RemoveRemote(remotePath)
+}
+
+// Move 在服务端将文件或目录移动到新路径（WebDAV MOVE）
+// overwrite 为 false 时，目标已存在会返回错误而不是覆盖
+func (c *WebDAVClient) Move(oldPath, newPath string, overwrite bool) error {
+	return c.copyMove("MOVE", oldPath, newPath, overwrite)
+}
+
+// Copy 在服务端将文件或目录复制到新路径（WebDAV COPY）
+// overwrite 为 false 时，目标已存在会返回错误而不是覆盖
+func (c *WebDAVClient) Copy(oldPath, newPath string, overwrite bool) error {
+	return c.copyMove("COPY", oldPath, newPath, overwrite)
+}
+
+// copyMove MOVE 和 COPY 的公共实现，目标父目录不存在时自动创建后重试一次
+func (c *WebDAVClient) copyMove(method, oldPath, newPath string, overwrite bool) error {
+	setHeaders := func(req *http.Request) {
+		req.Header.Set("Destination", c.destination(newPath))
+		req.Header.Set("Depth", "infinity")
+		if overwrite {
+			req.Header.Set("Overwrite", "T")
+		} else {
+			req.Header.Set("Overwrite", "F")
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.request(method, oldPath, nil, setHeaders)
+		if err != nil {
+			return fmt.Errorf("%s %s 到 %s 失败: %w", method, oldPath, newPath, wrapError(method, oldPath, err))
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusCreated, http.StatusNoContent:
+			return nil
+		case http.StatusConflict, http.StatusForbidden:
+			// 目标父目录不存在；标准要求返回 409，部分服务器（如基于 x/net/webdav 的实现）返回 403
+			if attempt == 0 {
+				parent := path.Dir(newPath)
+				if resp.StatusCode == http.StatusForbidden {
+					if exists, err := c.FileExists(parent); err != nil || exists {
+						break
+					}
+				}
+				if err := c.MakeDir(parent); err != nil {
+					return fmt.Errorf("%s %s 到 %s 失败: %w", method, oldPath, newPath, err)
+				}
+				continue
+			}
+		}
+		return fmt.Errorf("%s %s 到 %s 失败: %w", method, oldPath, newPath, newStatusError(method, oldPath, resp))
+	}
 }
 
 // FileExists 检查远程文件或目录是否存在
 func (c *WebDAVClient) FileExists(remotePath string) (bool, error) {
-	_, err := c.client.Stat(remotePath)
+	_, err := c.Stat(remotePath)
 	if err != nil {
-		if strings.Contains(err.Error(), "404") || strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, ErrNotFound) {
 			return false, nil
 		}
 		return false, err
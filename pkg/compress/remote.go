@@ -0,0 +1,511 @@
+package compress
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+)
+
+// Ext 压缩文件在服务器上的标记扩展名
+// 本地文件名本身以该扩展名结尾时总是压缩上传，保证服务器上的名称可以无歧义地还原
+const Ext = ".sws.gz"
+
+// Options 压缩层配置
+type Options struct {
+	Patterns []string // 需要压缩的文件匹配模式，不含 / 的模式匹配文件名，否则匹配相对路径
+	Level    int      // gzip 压缩级别 1-9
+}
+
+// Remote 在另一个 client.Remote 之上透明地压缩匹配的文件
+// 对调用方而言路径是原始文件名，文件大小是服务器上保存的压缩后大小
+type Remote struct {
+	inner    client.Remote
+	patterns []string
+	level    int
+
+	mu     sync.Mutex
+	stored map[string]string // 原始路径 -> 服务器上的实际路径，来自列表结果和上传记录
+	stale  map[string]string // 原始路径 -> 压缩设置变化后遗留的另一种形式
+}
+
+var _ client.Remote = (*Remote)(nil)
+
+// New 创建压缩层
+func New(inner client.Remote, opts Options) (*Remote, error) {
+	for _, pattern := range opts.Patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("无效的压缩匹配模式 %q: %w", pattern, err)
+		}
+	}
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, fmt.Errorf("无效的压缩级别: %d (可选 1-9)", opts.Level)
+	}
+
+	return &Remote{
+		inner:    inner,
+		patterns: opts.Patterns,
+		level:    level,
+		stored:   make(map[string]string),
+		stale:    make(map[string]string),
+	}, nil
+}
+
+// key 路径的统一形式
+func key(p string) string {
+	return "/" + strings.Trim(p, "/")
+}
+
+// shouldCompress 判断文件是否需要压缩
+func (r *Remote) shouldCompress(p string) bool {
+	p = key(p)
+	if strings.HasSuffix(p, Ext) {
+		return true
+	}
+	base := path.Base(p)
+	rel := strings.TrimPrefix(p, "/")
+	for _, pattern := range r.patterns {
+		target := base
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// target 返回文件按当前设置应保存在服务器上的路径
+func (r *Remote) target(p string) string {
+	if r.shouldCompress(p) {
+		return key(p) + Ext
+	}
+	return key(p)
+}
+
+// alternate 返回文件在服务器上可能存在的另一种形式，没有时返回空字符串
+func (r *Remote) alternate(p string) string {
+	p = key(p)
+	if strings.HasSuffix(p, Ext) {
+		return ""
+	}
+	if r.shouldCompress(p) {
+		return p
+	}
+	return p + Ext
+}
+
+// decode 将服务器上的路径还原为原始路径
+func decode(stored string) (string, bool) {
+	if strings.HasSuffix(stored, Ext) {
+		return strings.TrimSuffix(stored, Ext), true
+	}
+	return stored, false
+}
+
+// remember 记录原始路径在服务器上的实际路径
+func (r *Remote) remember(p, stored string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stored[key(p)] = key(stored)
+}
+
+// forget 清除路径及其子路径的记录
+func (r *Remote) forget(p string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p = key(p)
+	for _, m := range []map[string]string{r.stored, r.stale} {
+		for k := range m {
+			if k == p || strings.HasPrefix(k, p+"/") {
+				delete(m, k)
+			}
+		}
+	}
+}
+
+// lookup 返回已知的服务器路径
+func (r *Remote) lookup(p string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.stored[key(p)]
+	return stored, ok
+}
+
+// locate 查找原始路径在服务器上的实际路径，先使用已知记录，再依次检查两种形式
+func (r *Remote) locate(p string) (string, bool, error) {
+	if stored, ok := r.lookup(p); ok {
+		return stored, true, nil
+	}
+
+	candidates := []string{r.target(p)}
+	if alt := r.alternate(p); alt != "" {
+		candidates = append(candidates, alt)
+	}
+	for _, candidate := range candidates {
+		exists, err := r.inner.FileExists(candidate)
+		if err != nil {
+			return "", false, err
+		}
+		if exists {
+			r.remember(p, candidate)
+			return candidate, true, nil
+		}
+	}
+	return r.target(p), false, nil
+}
+
+// decodeList 还原列表中的路径，同一文件同时存在两种形式时保留符合当前设置的一种
+func (r *Remote) decodeList(files []client.FileInfo) []client.FileInfo {
+	index := make(map[string]int, len(files))
+	var result []client.FileInfo
+	for _, file := range files {
+		stored := file.Path
+		if !file.IsDir {
+			file.Path, _ = decode(stored)
+		}
+
+		if i, ok := index[key(file.Path)]; ok {
+			// 压缩设置变化后，同一文件可能以两种形式存在，保留符合当前设置的一种
+			keepStored, dropStored := r.storedOf(result[i]), key(stored)
+			if key(stored) == r.target(file.Path) {
+				result[i] = file
+				keepStored, dropStored = dropStored, keepStored
+			}
+			log.Printf("警告: %s 在服务器上同时存在压缩和未压缩两种形式，将使用 %s，另一份会在下次上传或删除时清理", file.Path, keepStored)
+			r.remember(file.Path, keepStored)
+			r.mu.Lock()
+			r.stale[key(file.Path)] = dropStored
+			r.mu.Unlock()
+			continue
+		}
+
+		if !file.IsDir {
+			r.remember(file.Path, stored)
+		}
+		index[key(file.Path)] = len(result)
+		result = append(result, file)
+	}
+	return result
+}
+
+// storedOf 返回列表条目在服务器上的实际路径
+func (r *Remote) storedOf(file client.FileInfo) string {
+	if stored, ok := r.lookup(file.Path); ok {
+		return stored
+	}
+	return key(file.Path)
+}
+
+// ListFiles 列出目录，返回原始文件名和服务器上的文件大小
+func (r *Remote) ListFiles(remotePath string) ([]client.FileInfo, error) {
+	files, err := r.inner.ListFiles(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeList(files), nil
+}
+
+// ListTree 递归列出目录树，返回原始路径和服务器上的文件大小
+func (r *Remote) ListTree(remotePath string) ([]client.FileInfo, error) {
+	files, err := r.inner.ListTree(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeList(files), nil
+}
+
+// Stat 获取文件信息
+func (r *Remote) Stat(remotePath string) (client.FileInfo, error) {
+	stored, _, err := r.locate(remotePath)
+	if err != nil {
+		return client.FileInfo{}, err
+	}
+	info, err := r.inner.Stat(stored)
+	if err != nil {
+		return info, err
+	}
+	info.Path = remotePath
+	return info, nil
+}
+
+// FileExists 检查文件或目录是否存在，压缩和未压缩两种形式都会检查
+func (r *Remote) FileExists(remotePath string) (bool, error) {
+	_, exists, err := r.locate(remotePath)
+	return exists, err
+}
+
+// MakeDir 创建目录
+func (r *Remote) MakeDir(remotePath string) error {
+	return r.inner.MakeDir(remotePath)
+}
+
+// ReadStream 返回解压后的文件内容
+func (r *Remote) ReadStream(remotePath string) (io.ReadCloser, error) {
+	stored, _, err := r.locate(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := r.inner.ReadStream(stored)
+	if err != nil {
+		return nil, err
+	}
+	if _, compressed := decode(stored); !compressed {
+		return reader, nil
+	}
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("解压 %s 失败: %w", remotePath, err)
+	}
+	return &gzipReadCloser{Reader: gz, body: reader}, nil
+}
+
+// gzipReadCloser 关闭时同时关闭解压器和底层响应
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (g *gzipReadCloser) Close() error {
+	err := g.Reader.Close()
+	if closeErr := g.body.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// DownloadFile 下载并解压文件到本地
+func (r *Remote) DownloadFile(remotePath, localPath string, modTime time.Time) error {
+	reader, err := r.ReadStream(remotePath)
+	if err != nil {
+		return fmt.Errorf("读取远程文件失败: %w", err)
+	}
+	defer reader.Close()
+
+	if err := client.WriteLocalFile(localPath, reader, modTime); err != nil {
+		return fmt.Errorf("解压 %s 失败: %w", remotePath, err)
+	}
+	return nil
+}
+
+// UploadFile 上传本地文件，匹配压缩模式的文件会先压缩
+func (r *Remote) UploadFile(localPath, remotePath string, modTime time.Time) (client.UploadResult, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return client.UploadResult{}, fmt.Errorf("获取本地文件信息失败: %w", err)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return client.UploadResult{}, fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer file.Close()
+
+	return r.UploadStream(remotePath, file, info.Size(), modTime)
+}
+
+// UploadStream 上传数据流，size 为原始长度
+// 压缩后的长度事先未知，因此先压缩到临时文件再上传；返回结果中的大小为服务器上保存的大小
+func (r *Remote) UploadStream(remotePath string, reader io.Reader, size int64, modTime time.Time) (client.UploadResult, error) {
+	target := r.target(remotePath)
+	if _, compressed := decode(target); !compressed {
+		result, err := r.inner.UploadStream(target, reader, size, modTime)
+		if err == nil {
+			r.uploaded(remotePath, target)
+		}
+		return result, err
+	}
+
+	spool, err := os.CreateTemp("", "sws-compress-*")
+	if err != nil {
+		return client.UploadResult{}, fmt.Errorf("创建压缩临时文件失败: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	gz, err := gzip.NewWriterLevel(spool, r.level)
+	if err != nil {
+		return client.UploadResult{}, err
+	}
+	gz.Name = path.Base(remotePath)
+	gz.ModTime = modTime
+	if _, err := io.Copy(gz, reader); err != nil {
+		return client.UploadResult{}, fmt.Errorf("压缩 %s 失败: %w", remotePath, err)
+	}
+	if err := gz.Close(); err != nil {
+		return client.UploadResult{}, fmt.Errorf("压缩 %s 失败: %w", remotePath, err)
+	}
+
+	compressedSize, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return client.UploadResult{}, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return client.UploadResult{}, err
+	}
+
+	result, err := r.inner.UploadStream(target, spool, compressedSize, modTime)
+	if err != nil {
+		return result, err
+	}
+	result.Size = compressedSize
+	r.uploaded(remotePath, target)
+	return result, nil
+}
+
+// uploaded 上传成功后记录实际路径，并删除压缩设置变化前遗留的另一种形式
+func (r *Remote) uploaded(remotePath, target string) {
+	p, target := key(remotePath), key(target)
+	r.mu.Lock()
+	leftovers := []string{r.stored[p], r.stale[p]}
+	delete(r.stale, p)
+	r.stored[p] = target
+	r.mu.Unlock()
+
+	for _, leftover := range leftovers {
+		if leftover == "" || leftover == target {
+			continue
+		}
+		if err := r.inner.RemoveRemote(leftover); err != nil && !errors.Is(err, client.ErrNotFound) {
+			log.Printf("警告: 删除旧的文件形式 %s 失败: %v", leftover, err)
+		}
+	}
+}
+
+// movedPath 返回移动或复制后的服务器路径，文件保持原有的压缩形式
+func (r *Remote) movedPath(src, newPath string) string {
+	if _, compressed := decode(src); compressed {
+		return key(newPath) + Ext
+	}
+	return key(newPath)
+}
+
+// Move 移动文件或目录
+func (r *Remote) Move(oldPath, newPath string, overwrite bool) error {
+	src, _, err := r.locate(oldPath)
+	if err != nil {
+		return err
+	}
+	dst := r.movedPath(src, newPath)
+	if err := r.inner.Move(src, dst, overwrite); err != nil {
+		return err
+	}
+	r.forget(oldPath)
+	r.forget(newPath)
+	return nil
+}
+
+// Copy 复制文件或目录，压缩后的内容直接在服务端复制
+func (r *Remote) Copy(oldPath, newPath string, overwrite bool) error {
+	src, _, err := r.locate(oldPath)
+	if err != nil {
+		return err
+	}
+	dst := r.movedPath(src, newPath)
+	if err := r.inner.Copy(src, dst, overwrite); err != nil {
+		return err
+	}
+	r.forget(newPath)
+	return nil
+}
+
+// RemoveRemote 删除文件或目录，同时删除遗留的另一种形式
+func (r *Remote) RemoveRemote(remotePath string) error {
+	return r.remove(remotePath, r.inner.RemoveRemote)
+}
+
+// RemoveRemoteAll 递归删除目录
+func (r *Remote) RemoveRemoteAll(remotePath string) error {
+	return r.remove(remotePath, r.inner.RemoveRemoteAll)
+}
+
+// remove 删除路径在服务器上的实际形式
+func (r *Remote) remove(remotePath string, del func(string) error) error {
+	stored, exists, err := r.locate(remotePath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return del(stored)
+	}
+	if err := del(stored); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	stale := r.stale[key(remotePath)]
+	r.mu.Unlock()
+	if stale != "" {
+		if err := del(stale); err != nil && !errors.Is(err, client.ErrNotFound) {
+			log.Printf("警告: 删除旧的文件形式 %s 失败: %v", stale, err)
+		}
+	}
+	r.forget(remotePath)
+	return nil
+}
+
+// SetModTime 设置文件实际形式或目录的修改时间
+func (r *Remote) SetModTime(remotePath string, modTime time.Time) bool {
+	stored, _, err := r.locate(remotePath)
+	if err != nil {
+		return false
+	}
+	return r.inner.SetModTime(stored, modTime)
+}
+
+// SetProperty 在文件的实际形式上保存自定义属性
+func (r *Remote) SetProperty(remotePath, name, value string) error {
+	stored, _, err := r.locate(remotePath)
+	if err != nil {
+		return err
+	}
+	return r.inner.SetProperty(stored, name, value)
+}
+
+// Properties 读取目录及其直接子条目的自定义属性，返回原始路径
+func (r *Remote) Properties(remotePath, name string) (map[string]string, error) {
+	props, err := r.inner.Properties(remotePath, name)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(props))
+	for p, value := range props {
+		plain, _ := decode(p)
+		result[plain] = value
+	}
+	return result, nil
+}
+
+// AllProperties 读取文件在服务器上实际路径（可能带有压缩扩展名）的全部属性
+func (r *Remote) AllProperties(remotePath string) ([]client.Property, error) {
+	stored, _, err := r.locate(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	return r.inner.AllProperties(stored)
+}
+
+// Capabilities 返回底层服务器的能力
+func (r *Remote) Capabilities() *client.Capabilities {
+	return r.inner.Capabilities()
+}
+
+// ListIssues 返回底层的列表问题次数
+func (r *Remote) ListIssues() int64 {
+	return r.inner.ListIssues()
+}
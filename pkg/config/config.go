@@ -1,12 +1,14 @@
 package config
 
 import (
-	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"SyncUsingWebDav/pkg/secret"
+
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -18,6 +20,8 @@ const (
 	BackupMode SyncMode = "backup"
 	// RestoreMode 恢复模式：从WebDAV同步到本地
 	RestoreMode SyncMode = "restore"
+	// SnapshotMode 快照模式：为本地目录创建一个新的快照
+	SnapshotMode SyncMode = "snapshot"
 )
 
 // Config 存储应用程序配置
@@ -26,19 +30,98 @@ type Config struct {
 	WebdavURL      string `toml:"webdav_url"`
 	WebdavUsername string `toml:"webdav_username"`
 	WebdavPassword string `toml:"webdav_password"`
+	ServerProfile  string `toml:"server_profile"` // 服务器配置档案: auto, generic, nextcloud, owncloud, alist, jianguoyun
+
+	// WebdavPasswordSource 密码来源，设置后覆盖 webdav_password: env:变量名, file:路径, cmd:命令, keyring:服务名/账户名
+	WebdavPasswordSource string `toml:"webdav_password_source,omitempty"`
+
+	// 其他认证方式
+	BearerToken        string            `toml:"bearer_token,omitempty"`         // Bearer/OAuth 访问令牌，设置后不再使用用户名和密码
+	BearerTokenSource  string            `toml:"bearer_token_source,omitempty"`  // 访问令牌来源，格式与 webdav_password_source 相同
+	BearerTokenCommand string            `toml:"bearer_token_command,omitempty"` // 获取新令牌的命令，令牌为空或服务器返回 401 时运行
+	AuthHeaders        map[string]string `toml:"auth_headers,omitempty"`         // 每个请求附加的认证请求头，如 X-API-Key
+
+	// TLS设置
+	TLSClientCert string   `toml:"tls_client_cert,omitempty"` // 双向TLS的客户端证书（PEM）
+	TLSClientKey  string   `toml:"tls_client_key,omitempty"`  // 客户端证书的私钥（PEM），为空时从证书文件中读取
+	TLSCACert     string   `toml:"tls_ca_cert,omitempty"`     // 验证服务器证书的CA证书（PEM），用于私有CA
+	TLSPinSHA256  []string `toml:"tls_pin_sha256,omitempty"`  // 服务器证书的SHA-256指纹，设置后只接受指纹匹配的证书
+
+	// HTTP传输设置
+	Proxy                 string            `toml:"proxy"`                    // 代理地址: http://、https:// 或 socks5://，为空时使用环境变量，none 表示不使用代理
+	ConnectTimeout        Duration          `toml:"connect_timeout"`          // 建立连接的超时时间，0 表示不限制
+	TLSHandshakeTimeout   Duration          `toml:"tls_handshake_timeout"`    // TLS握手的超时时间，0 表示不限制
+	ResponseHeaderTimeout Duration          `toml:"response_header_timeout"`  // 等待响应头的超时时间，0 表示不限制
+	IdleConnTimeout       Duration          `toml:"idle_conn_timeout"`        // 空闲连接的保留时间
+	TLSInsecureSkipVerify bool              `toml:"tls_insecure_skip_verify"` // 不验证服务器证书，仅用于测试环境
+	HTTP2                 bool              `toml:"http2"`                    // 是否允许使用 HTTP/2
+	UserAgent             string            `toml:"user_agent"`               // User-Agent 请求头
+	Headers               map[string]string `toml:"headers,omitempty"`        // 每个请求附加的请求头
 
 	// 本地同步配置
 	LocalDir string `toml:"local_dir"`
 
 	// 同步模式设置
-	Mode           string `toml:"mode"`            // 同步模式: backup (本地->WebDAV) 或 restore (WebDAV->本地)
+	Mode           string `toml:"mode"`            // 同步模式: backup (本地->WebDAV)、restore (WebDAV->本地) 或 snapshot (创建快照)
 	SyncDelete     bool   `toml:"sync_delete"`     // 是否删除目标位置中源位置不存在的文件/目录
 	CompareContent bool   `toml:"compare_content"` // 是否比较文件内容而不仅仅是时间戳
 
 	// 并发和重试设置
-	MaxConcurrent int           `toml:"max_concurrent"`
-	MaxRetries    int           `toml:"max_retries"`
-	RetryDelay    time.Duration `toml:"retry_delay"`
+	MaxConcurrent int      `toml:"max_concurrent"`
+	MaxRetries    int      `toml:"max_retries"`
+	RetryDelay    Duration `toml:"retry_delay"`
+
+	// 状态和索引设置
+	StateDir   string `toml:"state_dir"`   // 同步状态文件（本地索引等）的存放目录，为空时使用本地同步目录下的 .sws
+	UseJournal bool   `toml:"use_journal"` // 是否使用本地变更索引加速备份
+
+	// 客户端加密设置
+	Encryption           bool   `toml:"encryption"`            // 是否在上传前加密文件内容
+	EncryptionPassphrase string `toml:"encryption_passphrase"` // 加密口令，与 encryption_key_file 二选一
+	EncryptionKeyFile    string `toml:"encryption_key_file"`   // 32字节密钥文件路径
+	EncryptionNames      string `toml:"encryption_names"`      // 文件名处理方式: off, encrypt, obfuscate
+
+	// EncryptionPassphraseSource 加密口令来源，格式与 webdav_password_source 相同，设置后覆盖 encryption_passphrase
+	EncryptionPassphraseSource string `toml:"encryption_passphrase_source,omitempty"`
+
+	// 压缩设置
+	Compression      string   `toml:"compression"`       // 压缩算法: off 或 gzip
+	CompressPatterns []string `toml:"compress_patterns"` // 需要压缩的文件匹配模式
+	CompressLevel    int      `toml:"compress_level"`    // 压缩级别 1-9
+
+	// 历史版本设置
+	Versioning       bool `toml:"versioning"`         // 覆盖或删除远程文件前是否保存历史版本
+	VersionsKeepLast int  `toml:"versions_keep_last"` // 每个文件保留的最近版本数
+	VersionsKeepDays int  `toml:"versions_keep_days"` // 保留最近多少天内每天的最后一个版本
+	VersionsMaxSize  Size `toml:"versions_max_size"`  // 历史版本总大小上限，如 "10GiB"，0 表示不限制
+
+	// 本地回收站设置
+	UseTrash      bool   `toml:"use_trash"`       // 恢复模式删除或覆盖本地文件前是否移入回收站
+	TrashDir      string `toml:"trash_dir"`       // 回收站目录，为空时使用状态目录下的 trash
+	TrashKeepDays int    `toml:"trash_keep_days"` // 回收站中条目的保留天数，0 表示永久保留
+
+	// 删除安全检查设置
+	DeleteMaxCount   int  `toml:"delete_max_count"`   // 单次运行最多删除的条目数，0 表示不限制
+	DeleteMaxPercent int  `toml:"delete_max_percent"` // 单次运行最多删除目标位置条目的百分比，0 表示不限制
+	ForceDelete      bool `toml:"-"`                  // 跳过删除安全检查，只能通过 -force-delete 参数设置
+
+	// 符号链接设置
+	Symlinks string `toml:"symlinks"` // 符号链接处理方式: skip (跳过), follow (跟随链接), link (保存为链接描述文件)
+
+	// 文件名设置
+	NameNormalization string `toml:"name_normalization"` // 文件名的 Unicode 规范化方式: nfc, nfd, off (保持原样)
+	IllegalNames      string `toml:"illegal_names"`      // 无法在 Windows 上使用的文件名: report (报告), escape (转义), off (不检查)
+	CaseCollisions    string `toml:"case_collisions"`    // 只有大小写不同的文件名: auto (恢复到 Windows/macOS 时检查), check (始终检查), off (不检查)
+
+	// 文件元数据设置
+	Metadata       bool     `toml:"metadata"`        // 是否保存并恢复文件权限、属主和扩展属性
+	MetadataStore  string   `toml:"metadata_store"`  // 元数据保存方式: auto (自动), props (服务器自定义属性), sidecar (元数据文件)
+	MetadataXattrs []string `toml:"metadata_xattrs"` // 需要保存的扩展属性名模式，为空时不保存扩展属性
+
+	// 快照设置
+	Snapshot string `toml:"-"` // 恢复模式下要恢复的快照编号（latest 表示最新），只能通过 -snapshot 参数设置
+
+	plaintext []string // 配置文件中以明文保存的凭据配置项
 }
 
 // 默认配置文件名
@@ -49,60 +132,69 @@ const (
 // NewDefaultConfig 返回默认配置
 func NewDefaultConfig() *Config {
 	return &Config{
-		WebdavURL:      "http://localhost:5244/dav",
-		WebdavUsername: "guest",
-		WebdavPassword: "guest",
-		LocalDir:       "./sync",
-		Mode:           string(RestoreMode), // 默认为恢复模式（从WebDAV到本地）
-		SyncDelete:     false,               // 默认不删除文件
-		CompareContent: false,               // 默认只比较修改时间
-		MaxConcurrent:  5,
-		MaxRetries:     3,
-		RetryDelay:     2 * time.Second,
-	}
-}
+		WebdavURL:         "http://localhost:5244/dav",
+		WebdavUsername:    "guest",
+		WebdavPassword:    "guest",
+		ServerProfile:     "auto", // 默认根据探测结果自动选择
+		LocalDir:          "./sync",
+		Mode:              string(RestoreMode), // 默认为恢复模式（从WebDAV到本地）
+		SyncDelete:        false,               // 默认不删除文件
+		CompareContent:    false,               // 默认只比较修改时间
+		MaxConcurrent:     5,
+		MaxRetries:        3,
+		RetryDelay:        Duration(2 * time.Second),
+		StateDir:          "",    // 默认保存在本地同步目录下，不同配置互不影响
+		UseJournal:        false, // 默认不使用本地变更索引
+		Encryption:        false, // 默认不加密
+		EncryptionNames:   "encrypt",
+		Compression:       "off", // 默认不压缩
+		CompressPatterns:  []string{"*.txt", "*.log", "*.csv", "*.json", "*.xml", "*.sql"},
+		CompressLevel:     6,
+		Versioning:        false, // 默认不保存历史版本
+		VersionsKeepLast:  10,
+		VersionsKeepDays:  30,
+		VersionsMaxSize:   0,
+		UseTrash:          true, // 默认将删除和覆盖的本地文件移入回收站
+		TrashDir:          "",
+		TrashKeepDays:     30,
+		DeleteMaxCount:    1000,
+		DeleteMaxPercent:  50,
+		Symlinks:          "skip",
+		NameNormalization: "nfc",
+		IllegalNames:      "report",
+		CaseCollisions:    "auto",
+		Metadata:          false, // 默认不保存文件元数据
+		MetadataStore:     "auto",
+		MetadataXattrs:    []string{"user.*"},
 
-// LoadFromArgs 从命令行参数加载配置
-func (c *Config) LoadFromArgs() *Config {
-	// 解析命令行参数
-	configFile := flag.String("config", DefaultConfigFile, "配置文件路径")
-	mode := flag.String("mode", "", "同步模式: backup (本地->WebDAV) 或 restore (WebDAV->本地)")
-	syncDelete := flag.Bool("sync-delete", false, "是否删除目标位置中源位置不存在的文件/目录")
-	flag.Parse()
-
-	// 尝试加载配置文件
-	if err := c.LoadFromFile(*configFile); err != nil {
-		// 如果配置文件不存在，创建并保存默认配置
-		if os.IsNotExist(err) {
-			fmt.Printf("配置文件 %s 不存在，创建默认配置文件\n", *configFile)
-			if err := c.SaveToFile(*configFile); err != nil {
-				fmt.Printf("创建配置文件失败: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Printf("已创建默认配置文件 %s，请根据需要修改配置后重新运行程序\n", *configFile)
-			os.Exit(1)
-		} else {
-			fmt.Printf("加载配置文件失败: %v\n", err)
-			os.Exit(1)
-		}
+		Proxy:                 "", // 默认使用 HTTP_PROXY 等环境变量
+		ConnectTimeout:        Duration(30 * time.Second),
+		TLSHandshakeTimeout:   Duration(15 * time.Second),
+		ResponseHeaderTimeout: Duration(5 * time.Minute), // 部分服务器在大文件上传完成后需要较长时间才返回
+		IdleConnTimeout:       Duration(90 * time.Second),
+		TLSInsecureSkipVerify: false,
+		HTTP2:                 true,
+		UserAgent:             "SyncUsingWebDav",
 	}
+}
 
-	// 命令行参数优先级高于配置文件
-	if *mode != "" {
-		c.Mode = *mode
+// Load 加载配置文件，并依次应用 SWS_ 环境变量和命令行参数（格式为 配置项=值），最后展开路径
+// 配置文件不存在时返回的错误满足 os.IsNotExist
+func (c *Config) Load(filePath string, sets []string) error {
+	if err := c.LoadFromFile(filePath); err != nil {
+		return err
 	}
+	c.warnPlaintext(filePath)
 
-	if *syncDelete {
-		c.SyncDelete = true
+	// 优先级从低到高依次为：配置文件、SWS_ 环境变量、命令行参数
+	if err := c.applyEnv(); err != nil {
+		return err
 	}
-
-	// 验证模式是否有效
-	if c.Mode != string(BackupMode) && c.Mode != string(RestoreMode) {
-		fmt.Printf("无效的同步模式: %s, 使用默认的恢复模式\n", c.Mode)
-		c.Mode = string(RestoreMode)
+	if err := c.applySets(sets); err != nil {
+		return err
 	}
-
-	return c
+	c.expandPaths()
+	return nil
 }
 
 // LoadFromFile 从配置文件加载配置
@@ -117,6 +209,60 @@ func (c *Config) LoadFromFile(filePath string) error {
 		return fmt.Errorf("解析配置文件失败: %v", err)
 	}
 
+	// 记录文件中实际写了哪些明文凭据，默认值不算
+	var secrets struct {
+		WebdavPassword       string `toml:"webdav_password"`
+		BearerToken          string `toml:"bearer_token"`
+		EncryptionPassphrase string `toml:"encryption_passphrase"`
+	}
+	if err := toml.Unmarshal(data, &secrets); err == nil {
+		c.plaintext = nil
+		if secrets.WebdavPassword != "" {
+			c.plaintext = append(c.plaintext, "webdav_password")
+		}
+		if secrets.BearerToken != "" {
+			c.plaintext = append(c.plaintext, "bearer_token")
+		}
+		if secrets.EncryptionPassphrase != "" {
+			c.plaintext = append(c.plaintext, "encryption_passphrase")
+		}
+	}
+
+	return nil
+}
+
+// warnPlaintext 配置文件中保存了明文凭据且可以被其他用户读取时给出警告
+func (c *Config) warnPlaintext(filePath string) {
+	if len(c.plaintext) == 0 || !secret.WorldReadable(filePath) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "警告: 配置文件 %s 可以被其他用户读取，其中以明文保存了 %s；请执行 chmod 600 %s，或改用对应的 *_source 配置项引用凭据\n",
+		filePath, strings.Join(c.plaintext, ", "), filePath)
+}
+
+// ResolveSecrets 从配置的凭据来源读取WebDAV密码、访问令牌和加密口令，设置了来源时覆盖配置文件中的明文值
+func (c *Config) ResolveSecrets() error {
+	if c.WebdavPasswordSource != "" {
+		password, err := secret.Resolve(c.WebdavPasswordSource, c.WebdavUsername)
+		if err != nil {
+			return fmt.Errorf("读取 webdav_password_source 失败: %w", err)
+		}
+		c.WebdavPassword = password
+	}
+	if c.BearerTokenSource != "" {
+		token, err := secret.Resolve(c.BearerTokenSource, c.WebdavUsername)
+		if err != nil {
+			return fmt.Errorf("读取 bearer_token_source 失败: %w", err)
+		}
+		c.BearerToken = token
+	}
+	if c.EncryptionPassphraseSource != "" {
+		passphrase, err := secret.Resolve(c.EncryptionPassphraseSource, "encryption")
+		if err != nil {
+			return fmt.Errorf("读取 encryption_passphrase_source 失败: %w", err)
+		}
+		c.EncryptionPassphrase = passphrase
+	}
 	return nil
 }
 
@@ -134,8 +280,12 @@ func (c *Config) SaveToFile(filePath string) error {
 		return fmt.Errorf("序列化配置失败: %v", err)
 	}
 
-	// 写入文件
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	// 配置中可能含有密码，只允许属主读写
+	// os.WriteFile 只在创建文件时设置权限，覆盖已有文件前先修改其权限，避免写入的内容被其他用户读取
+	if err := os.Chmod(filePath, 0600); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("修改配置文件权限失败: %v", err)
+	}
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
 		return fmt.Errorf("写入配置文件失败: %v", err)
 	}
 
@@ -147,6 +297,27 @@ func (c *Config) EnsureLocalDir() error {
 	return os.MkdirAll(c.LocalDir, 0755)
 }
 
+// StateDirName 默认的状态目录名，位于本地同步目录下，不参与同步
+const StateDirName = ".sws"
+
+// StatePath 返回状态目录下指定文件的路径
+// 未设置 state_dir 时状态目录为本地同步目录下的 .sws，使用不同同步目录的配置不会共用状态文件
+func (c *Config) StatePath(name string) string {
+	dir := c.StateDir
+	if dir == "" {
+		dir = filepath.Join(c.LocalDir, StateDirName)
+	}
+	return filepath.Join(dir, name)
+}
+
+// TrashPath 返回回收站目录，未设置 trash_dir 时为状态目录下的 trash
+func (c *Config) TrashPath() string {
+	if c.TrashDir != "" {
+		return c.TrashDir
+	}
+	return c.StatePath("trash")
+}
+
 // GetSyncMode 获取当前同步模式
 func (c *Config) GetSyncMode() SyncMode {
 	switch c.Mode {
@@ -154,6 +325,8 @@ func (c *Config) GetSyncMode() SyncMode {
 		return BackupMode
 	case string(RestoreMode):
 		return RestoreMode
+	case string(SnapshotMode):
+		return SnapshotMode
 	default:
 		return RestoreMode
 	}
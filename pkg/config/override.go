@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"SyncUsingWebDav/pkg/util"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// EnvPrefix 覆盖配置项的环境变量前缀，如 SWS_MAX_CONCURRENT 覆盖 max_concurrent
+const EnvPrefix = "SWS_"
+
+// SetFlags 可以重复使用的 -set 命令行参数
+type SetFlags []string
+
+// String 实现 flag.Value 接口
+func (f *SetFlags) String() string {
+	return strings.Join(*f, ", ")
+}
+
+// Set 实现 flag.Value 接口
+func (f *SetFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("格式应为 配置项=值")
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// Keys 返回所有可以在配置文件中设置的配置项名称
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+		if field.IsExported() && name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Set 按配置文件中的写法设置一个配置项
+// 值按 TOML 解析，不是有效的 TOML 值时作为字符串处理，因此 -set local_dir=/data 不需要加引号
+// 表类型的配置项可以用 headers.X-Foo=bar 设置其中一项
+func (c *Config) Set(key, value string) error {
+	key = strings.TrimSpace(key)
+	top, _, _ := strings.Cut(key, ".")
+	if !isKey(top) {
+		return fmt.Errorf("未知的配置项 %q", top)
+	}
+	value = strings.TrimSpace(value)
+	if err := toml.Unmarshal([]byte(key+" = "+value), c); err == nil {
+		return nil
+	}
+	if err := toml.Unmarshal([]byte(key+" = "+quote(value)), c); err != nil {
+		return fmt.Errorf("无法设置 %s: %v", key, err)
+	}
+	return nil
+}
+
+// applyEnv 用 SWS_ 开头的环境变量覆盖配置项，变量名为配置项名称的大写形式
+func (c *Config) applyEnv() error {
+	for _, key := range Keys() {
+		name := EnvPrefix + strings.ToUpper(key)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := c.Set(key, value); err != nil {
+				return fmt.Errorf("环境变量 %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applySets 应用 -set 等命令行参数，每一项的格式为 配置项=值
+func (c *Config) applySets(sets []string) error {
+	for _, set := range sets {
+		key, value, _ := strings.Cut(set, "=")
+		if err := c.Set(key, value); err != nil {
+			return fmt.Errorf("参数 %s: %w", set, err)
+		}
+	}
+	return nil
+}
+
+// expandPaths 展开路径类配置项中的环境变量和开头的 ~
+func (c *Config) expandPaths() {
+	for _, p := range []*string{
+		&c.LocalDir, &c.StateDir, &c.TrashDir, &c.EncryptionKeyFile,
+		&c.TLSClientCert, &c.TLSClientKey, &c.TLSCACert,
+	} {
+		*p = util.ExpandPath(*p)
+	}
+}
+
+// isKey 判断是否为配置文件中的配置项名称
+func isKey(key string) bool {
+	for _, k := range Keys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// quote 将任意字符串转换为 TOML 基本字符串
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		key, value string
+		check      func(c *Config) any
+		want       any
+		ok         bool
+	}{
+		{"local_dir", "/data/sync", func(c *Config) any { return c.LocalDir }, "/data/sync", true},
+		{"local_dir", `"/data/quoted"`, func(c *Config) any { return c.LocalDir }, "/data/quoted", true},
+		{"max_concurrent", "8", func(c *Config) any { return c.MaxConcurrent }, 8, true},
+		{" sync_delete ", " true ", func(c *Config) any { return c.SyncDelete }, true, true},
+		{"retry_delay", "500ms", func(c *Config) any { return c.RetryDelay }, Duration(500 * time.Millisecond), true},
+		{"retry_delay", "2000000000", func(c *Config) any { return c.RetryDelay }, Duration(2 * time.Second), true},
+		{"versions_max_size", "10MiB", func(c *Config) any { return c.VersionsMaxSize }, Size(10 << 20), true},
+		{"compress_patterns", `["*.md"]`, func(c *Config) any { return c.CompressPatterns }, []string{"*.md"}, true},
+		{"headers.X-Test", "a b", func(c *Config) any { return c.Headers["X-Test"] }, "a b", true},
+		{"webdav_password", `pa"ss\word`, func(c *Config) any { return c.WebdavPassword }, `pa"ss\word`, true},
+		{"max_concurrent", "many", nil, nil, false},
+		{"no_such_key", "1", nil, nil, false},
+		{"retry_delay", "soon", nil, nil, false},
+	}
+	for _, tt := range tests {
+		c := NewDefaultConfig()
+		err := c.Set(tt.key, tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(%q, %q) = %v, want ok=%v", tt.key, tt.value, err, tt.ok)
+			continue
+		}
+		if tt.ok {
+			if got := tt.check(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Set(%q, %q) 后的值为 %v, want %v", tt.key, tt.value, got, tt.want)
+			}
+		}
+	}
+}
+
+// TestLoadPrecedence 优先级从低到高依次为配置文件、SWS_ 环境变量、-set 参数
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	data := "max_concurrent = 2\nmax_retries = 4\nretry_delay = '1s'\nlocal_dir = '$SWS_TEST_HOME/sync'\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SWS_TEST_HOME", "/home/test")
+	t.Setenv("SWS_MAX_RETRIES", "6")
+	t.Setenv("SWS_RETRY_DELAY", "3s")
+
+	c := NewDefaultConfig()
+	if err := c.Load(path, []string{"retry_delay=5s", "sync_delete=true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key       string
+		got, want any
+	}{
+		{"max_concurrent", c.MaxConcurrent, 2},
+		{"max_retries", c.MaxRetries, 6},
+		{"retry_delay", c.RetryDelay, Duration(5 * time.Second)},
+		{"sync_delete", c.SyncDelete, true},
+		{"local_dir", c.LocalDir, "/home/test/sync"},
+		{"mode", c.Mode, string(RestoreMode)},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.key, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("max_retries = 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewDefaultConfig().Load(filepath.Join(t.TempDir(), "missing.toml"), nil); !os.IsNotExist(err) {
+		t.Errorf("配置文件不存在时 Load() = %v, want os.IsNotExist", err)
+	}
+	if err := NewDefaultConfig().Load(path, []string{"unknown=1"}); err == nil {
+		t.Error("未知的 -set 配置项应该返回错误")
+	}
+	t.Setenv("SWS_MAX_CONCURRENT", "many")
+	if err := NewDefaultConfig().Load(path, nil); err == nil {
+		t.Error("无效的环境变量值应该返回错误")
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration 配置文件中的时间长度，可以写作 "2s"、"500ms"、"1h30m"、"7d"，
+// 也可以写作整数纳秒（兼容旧的配置文件，如 2000000000）
+type Duration time.Duration
+
+// UnmarshalText 解析带单位的时间长度或整数纳秒
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText 输出带单位的时间长度，省略末尾为零的分钟和秒，如 "5m"、"2h"
+func (d Duration) MarshalText() ([]byte, error) {
+	s := time.Duration(d).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return []byte(s), nil
+}
+
+// ParseDuration 解析时间长度，在 time.ParseDuration 的基础上支持整数纳秒和以 d 表示的天数
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的时间长度 %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间长度 %q (示例: 500ms, 2s, 5m, 1h30m, 7d)", s)
+	}
+	return v, nil
+}
+
+// Size 配置文件中的字节数，可以写作 "10MiB"、"1.5GB"、"512K"，也可以写作整数字节
+type Size int64
+
+// UnmarshalText 解析带单位的字节数或整数字节
+func (s *Size) UnmarshalText(text []byte) error {
+	v, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = Size(v)
+	return nil
+}
+
+// MarshalText 能整除时输出带二进制单位的字节数，否则输出整数字节
+func (s Size) MarshalText() ([]byte, error) {
+	v := int64(s)
+	for _, u := range []struct {
+		name string
+		size int64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if v != 0 && v%u.size == 0 {
+			return []byte(strconv.FormatInt(v/u.size, 10) + u.name), nil
+		}
+	}
+	return []byte(strconv.FormatInt(v, 10)), nil
+}
+
+// sizeUnits 字节数单位，不区分大小写；KB、MB 等为十进制单位，K、KiB 等为二进制单位
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kib": 1 << 10, "kb": 1e3,
+	"m": 1 << 20, "mib": 1 << 20, "mb": 1e6,
+	"g": 1 << 30, "gib": 1 << 30, "gb": 1e9,
+	"t": 1 << 40, "tib": 1 << 40, "tb": 1e12,
+}
+
+// ParseSize 解析带单位的字节数
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	number, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	if unit == "" {
+		if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	multiplier, ok := sizeUnits[unit]
+	n, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("无效的大小 %q (示例: 1024, 512K, 10MiB, 1.5GB)", s)
+	}
+	v := n * multiplier
+	if v > math.MaxInt64 || v < math.MinInt64 {
+		return 0, fmt.Errorf("大小 %q 超出范围", s)
+	}
+	return int64(v), nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+		ok   bool
+	}{
+		{"2000000000", 2 * time.Second, true},
+		{"0", 0, true},
+		{"500ms", 500 * time.Millisecond, true},
+		{"2s", 2 * time.Second, true},
+		{" 1h30m ", 90 * time.Minute, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"1.5d", 36 * time.Hour, true},
+		{"-1s", -time.Second, true},
+		{"", 0, false},
+		{"2", 2, true},
+		{"abc", 0, false},
+		{"xd", 0, false},
+		{"5 minutes", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v, ok=%v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"1024", 1024, true},
+		{"512K", 512 << 10, true},
+		{"10MiB", 10 << 20, true},
+		{"10mib", 10 << 20, true},
+		{"10 MiB", 10 << 20, true},
+		{"1.5GB", 1500000000, true},
+		{"1.5GiB", 3 << 29, true},
+		{"2TB", 2e12, true},
+		{"100b", 100, true},
+		{"", 0, false},
+		{"MiB", 0, false},
+		{"10XB", 0, false},
+		{"1e30TB", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d, ok=%v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestUnitsMarshal 输出的文本可以重新解析为相同的值
+func TestUnitsMarshal(t *testing.T) {
+	durations := []struct {
+		d    Duration
+		text string
+	}{
+		{Duration(2 * time.Second), "2s"},
+		{Duration(5 * time.Minute), "5m"},
+		{Duration(2 * time.Hour), "2h"},
+		{Duration(90 * time.Minute), "1h30m"},
+		{Duration(1500 * time.Millisecond), "1.5s"},
+	}
+	for _, tt := range durations {
+		text, _ := tt.d.MarshalText()
+		if string(text) != tt.text {
+			t.Errorf("Duration(%v).MarshalText() = %q, want %q", time.Duration(tt.d), text, tt.text)
+		}
+		var back Duration
+		if err := back.UnmarshalText(text); err != nil || back != tt.d {
+			t.Errorf("UnmarshalText(%q) = %v, %v, want %v", text, time.Duration(back), err, time.Duration(tt.d))
+		}
+	}
+
+	sizes := []struct {
+		s    Size
+		text string
+	}{
+		{0, "0"},
+		{1000, "1000"},
+		{1 << 10, "1KiB"},
+		{10 << 20, "10MiB"},
+		{3 << 30, "3GiB"},
+		{1<<40 + 1<<30, "1025GiB"},
+	}
+	for _, tt := range sizes {
+		text, _ := tt.s.MarshalText()
+		if string(text) != tt.text {
+			t.Errorf("Size(%d).MarshalText() = %q, want %q", tt.s, text, tt.text)
+		}
+		var back Size
+		if err := back.UnmarshalText(text); err != nil || back != tt.s {
+			t.Errorf("UnmarshalText(%q) = %d, %v, want %d", text, back, err, tt.s)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/secret"
+)
+
+// Problem 配置检查发现的一个问题
+type Problem struct {
+	Key     string // 对应的 TOML 配置项名称
+	Message string
+	Fatal   bool // 为 true 时程序拒绝运行，否则只给出警告
+}
+
+// String 返回 "配置项: 问题" 形式的描述
+func (p Problem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+// ValidationError 配置中存在致命问题时 Validate 返回的错误，包含全部致命问题
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Error 实现 error 接口，每个问题占一行
+func (e *ValidationError) Error() string {
+	lines := []string{fmt.Sprintf("配置中有 %d 个错误:", len(e.Problems))}
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validator 收集配置检查中发现的问题
+type validator struct {
+	problems []Problem
+}
+
+// fail 记录一个致命问题
+func (v *validator) fail(key, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...), Fatal: true})
+}
+
+// warn 记录一个只需提醒的问题
+func (v *validator) warn(key, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// oneOf 检查配置项是否为可选值之一
+func (v *validator) oneOf(key, value string, options ...string) {
+	for _, option := range options {
+		if value == option {
+			return
+		}
+	}
+	v.fail(key, "无效的值 %q (可选 %s)", value, strings.Join(options, ", "))
+}
+
+// atLeast 检查整数配置项的下限
+func (v *validator) atLeast(key string, value, min int64) {
+	if value < min {
+		v.fail(key, "不能小于 %d，当前为 %d", min, value)
+	}
+}
+
+// duration 检查时间配置项不是负数
+func (v *validator) duration(key string, value Duration) {
+	if value < 0 {
+		v.fail(key, "不能为负数，当前为 %s", time.Duration(value))
+	}
+}
+
+// ref 检查凭据来源引用的格式
+func (v *validator) ref(key, ref string) {
+	if ref == "" {
+		return
+	}
+	if err := secret.CheckRef(ref); err != nil {
+		v.fail(key, "%v", err)
+	}
+}
+
+// file 检查配置项指定的文件存在且不是目录
+func (v *validator) file(key, name string) {
+	if name == "" {
+		return
+	}
+	info, err := os.Stat(name)
+	switch {
+	case err != nil:
+		v.fail(key, "无法读取文件 %s: %v", name, err)
+	case info.IsDir():
+		v.fail(key, "%s 是一个目录", name)
+	}
+}
+
+// Validate 检查所有配置项，返回需要提醒的问题
+// 存在致命问题时返回 *ValidationError，其中包含全部致命问题，调用方应拒绝运行
+func (c *Config) Validate() ([]Problem, error) {
+	v := &validator{}
+	c.validateServer(v)
+	c.validateSync(v)
+	c.validateFeatures(v)
+
+	var warnings, fatal []Problem
+	for _, p := range v.problems {
+		if p.Fatal {
+			fatal = append(fatal, p)
+		} else {
+			warnings = append(warnings, p)
+		}
+	}
+	if len(fatal) > 0 {
+		return warnings, &ValidationError{Problems: fatal}
+	}
+	return warnings, nil
+}
+
+// validateServer 检查服务器地址、认证、TLS和网络配置
+func (c *Config) validateServer(v *validator) {
+	u, err := url.Parse(c.WebdavURL)
+	switch {
+	case c.WebdavURL == "":
+		v.fail("webdav_url", "不能为空")
+	case err != nil:
+		v.fail("webdav_url", "无效的地址: %v", err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		v.fail("webdav_url", "地址必须以 http:// 或 https:// 开头，当前为 %q", c.WebdavURL)
+	case u.Host == "":
+		v.fail("webdav_url", "地址中缺少主机名")
+	case u.Scheme == "http" && !isLoopback(u.Hostname()) && (c.WebdavPassword != "" || c.BearerToken != "" ||
+		c.WebdavPasswordSource != "" || c.BearerTokenSource != "" || c.BearerTokenCommand != ""):
+		v.warn("webdav_url", "使用未加密的 http:// 连接，密码或令牌会以明文传输")
+	}
+	if _, ok := client.LookupProfile(c.ServerProfile); !ok && !strings.EqualFold(c.ServerProfile, client.ProfileAuto) {
+		v.fail("server_profile", "无效的值 %q (可选 %s)", c.ServerProfile, strings.Join(client.ProfileNames(), ", "))
+	}
+
+	v.ref("webdav_password_source", c.WebdavPasswordSource)
+	v.ref("bearer_token_source", c.BearerTokenSource)
+	v.ref("encryption_passphrase_source", c.EncryptionPassphraseSource)
+	for key := range c.AuthHeaders {
+		if !validHeaderName(key) {
+			v.fail("auth_headers", "无效的请求头名称 %q", key)
+		}
+	}
+	for key := range c.Headers {
+		if !validHeaderName(key) {
+			v.fail("headers", "无效的请求头名称 %q", key)
+		}
+	}
+
+	v.file("tls_client_cert", c.TLSClientCert)
+	v.file("tls_client_key", c.TLSClientKey)
+	v.file("tls_ca_cert", c.TLSCACert)
+	if c.TLSClientKey != "" && c.TLSClientCert == "" {
+		v.fail("tls_client_key", "设置了私钥但没有设置 tls_client_cert")
+	}
+	for _, pin := range c.TLSPinSHA256 {
+		pin = strings.ReplaceAll(strings.TrimPrefix(pin, "sha256:"), ":", "")
+		if b, err := hex.DecodeString(pin); err != nil || len(b) != 32 {
+			v.fail("tls_pin_sha256", "无效的证书指纹 %q (应为64位十六进制SHA-256指纹)", pin)
+		}
+	}
+	if c.TLSInsecureSkipVerify {
+		v.warn("tls_insecure_skip_verify", "不验证服务器证书，连接可能被中间人窃听或篡改")
+	}
+
+	switch strings.ToLower(c.Proxy) {
+	case "", "none", "direct":
+	default:
+		if u, err := url.Parse(c.Proxy); err != nil || u.Host == "" {
+			v.fail("proxy", "无效的代理地址 %q", c.Proxy)
+		} else if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" && u.Scheme != "socks5h" {
+			v.fail("proxy", "不支持的代理类型 %q (可选 http, https, socks5)", u.Scheme)
+		}
+	}
+	v.duration("connect_timeout", c.ConnectTimeout)
+	v.duration("tls_handshake_timeout", c.TLSHandshakeTimeout)
+	v.duration("response_header_timeout", c.ResponseHeaderTimeout)
+	v.duration("idle_conn_timeout", c.IdleConnTimeout)
+}
+
+// validateSync 检查本地目录、同步模式、并发和删除相关配置
+func (c *Config) validateSync(v *validator) {
+	if c.LocalDir == "" {
+		v.fail("local_dir", "不能为空")
+	} else if info, err := os.Stat(c.LocalDir); err == nil && !info.IsDir() {
+		v.fail("local_dir", "%s 不是目录", c.LocalDir)
+	}
+	v.oneOf("mode", c.Mode, string(BackupMode), string(RestoreMode), string(SnapshotMode))
+
+	v.atLeast("max_concurrent", int64(c.MaxConcurrent), 1)
+	v.atLeast("max_retries", int64(c.MaxRetries), 1)
+	v.duration("retry_delay", c.RetryDelay)
+
+	v.atLeast("trash_keep_days", int64(c.TrashKeepDays), 0)
+	v.atLeast("delete_max_count", int64(c.DeleteMaxCount), 0)
+	if c.DeleteMaxPercent < 0 || c.DeleteMaxPercent > 100 {
+		v.fail("delete_max_percent", "应在 0 到 100 之间，当前为 %d", c.DeleteMaxPercent)
+	}
+	if c.SyncDelete && c.DeleteMaxCount == 0 && c.DeleteMaxPercent == 0 {
+		v.warn("delete_max_count", "启用了 sync_delete 但没有限制删除数量和比例，只在源位置为空或目录列表不完整时阻止删除")
+	}
+	if c.SyncDelete && c.Mode == string(RestoreMode) && !c.UseTrash {
+		v.warn("use_trash", "恢复模式启用了 sync_delete 但没有启用回收站，被删除的本地文件无法找回")
+	}
+
+	v.oneOf("symlinks", c.Symlinks, "skip", "follow", "link")
+	v.oneOf("name_normalization", c.NameNormalization, "nfc", "nfd", "off")
+	v.oneOf("illegal_names", c.IllegalNames, "report", "escape", "off")
+	v.oneOf("case_collisions", c.CaseCollisions, "auto", "check", "off")
+}
+
+// validateFeatures 检查加密、压缩、历史版本和元数据配置
+func (c *Config) validateFeatures(v *validator) {
+	if c.Encryption {
+		hasPassphrase := c.EncryptionPassphrase != "" || c.EncryptionPassphraseSource != ""
+		switch {
+		case !hasPassphrase && c.EncryptionKeyFile == "":
+			v.fail("encryption", "启用加密时需要设置 encryption_passphrase、encryption_passphrase_source 或 encryption_key_file")
+		case hasPassphrase && c.EncryptionKeyFile != "":
+			v.warn("encryption_key_file", "同时设置了加密口令和密钥文件，将使用密钥文件")
+		}
+		v.file("encryption_key_file", c.EncryptionKeyFile)
+	}
+	v.oneOf("encryption_names", strings.ToLower(c.EncryptionNames), "", "off", "encrypt", "obfuscate")
+
+	v.oneOf("compression", c.Compression, "", "off", "gzip")
+	if c.Compression == "gzip" {
+		if c.CompressLevel < 1 || c.CompressLevel > 9 {
+			v.fail("compress_level", "应在 1 到 9 之间，当前为 %d", c.CompressLevel)
+		}
+		for _, pattern := range c.CompressPatterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				v.fail("compress_patterns", "无效的匹配模式 %q: %v", pattern, err)
+			}
+		}
+	}
+
+	v.atLeast("versions_keep_last", int64(c.VersionsKeepLast), 0)
+	v.atLeast("versions_keep_days", int64(c.VersionsKeepDays), 0)
+	v.atLeast("versions_max_size", int64(c.VersionsMaxSize), 0)
+
+	v.oneOf("metadata_store", c.MetadataStore, "auto", "props", "sidecar")
+	for _, pattern := range c.MetadataXattrs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			v.fail("metadata_xattrs", "无效的匹配模式 %q: %v", pattern, err)
+		}
+	}
+}
+
+// isLoopback 判断主机名是否为本机地址
+func isLoopback(host string) bool {
+	return host == "localhost" || strings.HasPrefix(host, "127.") || host == "::1"
+}
+
+// validHeaderName 判断请求头名称是否只包含 HTTP token 字符
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		fatal    []string // 期望的致命问题对应的配置项
+		warnings []string // 期望的警告对应的配置项
+	}{
+		{"默认配置", func(c *Config) {}, nil, nil},
+		{"地址为空", func(c *Config) { c.WebdavURL = "" }, []string{"webdav_url"}, nil},
+		{"不支持的协议", func(c *Config) { c.WebdavURL = "ftp://example.com/dav" }, []string{"webdav_url"}, nil},
+		{"缺少主机名", func(c *Config) { c.WebdavURL = "https:///dav" }, []string{"webdav_url"}, nil},
+		{"明文传输密码", func(c *Config) { c.WebdavURL = "http://example.com/dav" }, nil, []string{"webdav_url"}},
+		{"本机地址不警告", func(c *Config) { c.WebdavURL = "http://127.0.0.1:5244/dav" }, nil, nil},
+		{"未知的服务器类型", func(c *Config) { c.ServerProfile = "unknown" }, []string{"server_profile"}, nil},
+		{"无效的请求头", func(c *Config) { c.Headers = map[string]string{"Bad Header": "x"} }, []string{"headers"}, nil},
+		{"证书文件不存在", func(c *Config) { c.TLSCACert = filepath.Join(dir, "missing.pem") }, []string{"tls_ca_cert"}, nil},
+		{"只设置私钥", func(c *Config) { c.TLSClientKey = filepath.Join(dir, "missing.key") },
+			[]string{"tls_client_key", "tls_client_key"}, nil},
+		{"无效的证书指纹", func(c *Config) { c.TLSPinSHA256 = []string{"sha256:abcd"} }, []string{"tls_pin_sha256"}, nil},
+		{"不验证证书", func(c *Config) { c.TLSInsecureSkipVerify = true }, nil, []string{"tls_insecure_skip_verify"}},
+		{"无效的代理", func(c *Config) { c.Proxy = "ftp://proxy:21" }, []string{"proxy"}, nil},
+		{"不使用代理", func(c *Config) { c.Proxy = "none" }, nil, nil},
+		{"负数超时", func(c *Config) { c.ConnectTimeout = -1 }, []string{"connect_timeout"}, nil},
+		{"本地目录为空", func(c *Config) { c.LocalDir = "" }, []string{"local_dir"}, nil},
+		{"无效的模式", func(c *Config) { c.Mode = "mirror" }, []string{"mode"}, nil},
+		{"并发数为0", func(c *Config) { c.MaxConcurrent = 0 }, []string{"max_concurrent"}, nil},
+		{"删除比例超过100", func(c *Config) { c.DeleteMaxPercent = 101 }, []string{"delete_max_percent"}, nil},
+		{"不限制删除", func(c *Config) {
+			c.SyncDelete = true
+			c.DeleteMaxCount = 0
+			c.DeleteMaxPercent = 0
+		}, nil, []string{"delete_max_count"}},
+		{"恢复删除不使用回收站", func(c *Config) {
+			c.SyncDelete = true
+			c.UseTrash = false
+		}, nil, []string{"use_trash"}},
+		{"无效的符号链接方式", func(c *Config) { c.Symlinks = "copy" }, []string{"symlinks"}, nil},
+		{"加密缺少口令", func(c *Config) { c.Encryption = true }, []string{"encryption"}, nil},
+		{"加密使用口令", func(c *Config) {
+			c.Encryption = true
+			c.EncryptionPassphrase = "secret"
+		}, nil, nil},
+		{"无效的压缩级别", func(c *Config) {
+			c.Compression = "gzip"
+			c.CompressLevel = 10
+		}, []string{"compress_level"}, nil},
+		{"无效的压缩模式", func(c *Config) {
+			c.Compression = "gzip"
+			c.CompressPatterns = []string{"["}
+		}, []string{"compress_patterns"}, nil},
+		{"无效的元数据保存方式", func(c *Config) { c.MetadataStore = "xattr" }, []string{"metadata_store"}, nil},
+		{"多个错误", func(c *Config) {
+			c.Mode = "mirror"
+			c.MaxRetries = 0
+			c.Symlinks = "copy"
+		}, []string{"mode", "max_retries", "symlinks"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewDefaultConfig()
+			c.LocalDir = dir
+			tt.modify(c)
+
+			warnings, err := c.Validate()
+			var fatal []string
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				for _, p := range verr.Problems {
+					fatal = append(fatal, p.Key)
+				}
+			} else if err != nil {
+				t.Fatalf("Validate() 返回了 %T: %v", err, err)
+			}
+			var warned []string
+			for _, p := range warnings {
+				warned = append(warned, p.Key)
+			}
+
+			if !reflect.DeepEqual(fatal, tt.fatal) {
+				t.Errorf("致命问题 = %v, want %v (%v)", fatal, tt.fatal, err)
+			}
+			if !reflect.DeepEqual(warned, tt.warnings) {
+				t.Errorf("警告 = %v, want %v", warned, tt.warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package crypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 加密文件格式：
+//
+//	magic(4) | version(1) | fileNonce(16) | chunk0 | chunk1 | ...
+//
+// 每个分块包含最多 ChunkSize 字节明文，使用 AES-256-GCM 单独加密并附带 16 字节认证标签。
+// 分块密钥由主密钥和 fileNonce 派生，分块序号和“是否最后一块”的标志写入 GCM nonce，
+// 因此分块既不能被重排也不能被截断。
+const (
+	ChunkSize = 64 * 1024
+
+	fileMagic   = "SWSE"
+	fileVersion = 1
+	nonceSize   = 16
+	headerSize  = len(fileMagic) + 1 + nonceSize
+	tagSize     = 16
+	chunkFull   = ChunkSize + tagSize
+
+	// pbkdf2Iterations 口令派生密钥的迭代次数
+	pbkdf2Iterations = 600000
+)
+
+// ErrBadKey 密钥错误或密文被篡改
+var ErrBadKey = errors.New("解密失败: 密钥错误或数据已损坏")
+
+// Cipher 文件内容和文件名的加解密器
+type Cipher struct {
+	contentKey []byte
+	nameKey    []byte
+	nameMacKey []byte
+	names      NameMode
+}
+
+// NewCipher 由32字节主密钥创建加解密器
+func NewCipher(masterKey []byte, names NameMode) (*Cipher, error) {
+	if len(masterKey) != 32 {
+		return nil, fmt.Errorf("主密钥长度必须为32字节，实际为%d字节", len(masterKey))
+	}
+	if _, err := ParseNameMode(string(names)); err != nil {
+		return nil, err
+	}
+
+	c := &Cipher{names: names}
+	var err error
+	if c.contentKey, err = hkdf.Key(sha256.New, masterKey, nil, "sws content", 32); err != nil {
+		return nil, err
+	}
+	if c.nameKey, err = hkdf.Key(sha256.New, masterKey, nil, "sws name", 32); err != nil {
+		return nil, err
+	}
+	if c.nameMacKey, err = hkdf.Key(sha256.New, masterKey, nil, "sws name mac", 32); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// DeriveKey 使用 PBKDF2-SHA256 由口令和盐派生主密钥
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+}
+
+// LoadKeyFile 从密钥文件读取主密钥
+// 文件内容可以是32字节原始数据，或其十六进制、Base64编码
+func LoadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	if len(data) == 32 {
+		return data, nil
+	}
+
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("密钥文件 %s 格式无效，需要32字节原始数据或其十六进制/Base64编码", path)
+}
+
+// EncryptedSize 返回明文长度对应的密文长度
+func EncryptedSize(plainSize int64) int64 {
+	chunks := (plainSize + ChunkSize - 1) / ChunkSize
+	if chunks == 0 {
+		chunks = 1 // 空文件也包含一个空的最后分块
+	}
+	return int64(headerSize) + plainSize + chunks*tagSize
+}
+
+// DecryptedSize 返回密文长度对应的明文长度
+func DecryptedSize(encSize int64) (int64, error) {
+	body := encSize - int64(headerSize)
+	if body < tagSize {
+		return 0, fmt.Errorf("密文长度 %d 无效", encSize)
+	}
+	chunks := (body + chunkFull - 1) / chunkFull
+	last := body - (chunks-1)*chunkFull
+	if last < tagSize {
+		return 0, fmt.Errorf("密文长度 %d 无效", encSize)
+	}
+	return body - chunks*tagSize, nil
+}
+
+// fileAEAD 由文件nonce派生该文件的分块加密器
+func (c *Cipher) fileAEAD(fileNonce []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, c.contentKey, fileNonce, "sws chunk", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 构造分块的GCM nonce：标志字节 | 3字节填充 | 8字节分块序号
+func chunkNonce(index uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	if final {
+		nonce[0] = 1
+	}
+	binary.BigEndian.PutUint64(nonce[4:], index)
+	return nonce
+}
+
+// NewEncryptReader 返回对 r 的内容进行流式加密的 Reader
+func (c *Cipher) NewEncryptReader(r io.Reader) (io.Reader, error) {
+	fileNonce := make([]byte, nonceSize)
+	if _, err := rand.Read(fileNonce); err != nil {
+		return nil, err
+	}
+	aead, err := c.fileAEAD(fileNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, fileMagic...)
+	header = append(header, fileVersion)
+	header = append(header, fileNonce...)
+
+	return &encryptReader{
+		src:  bufio.NewReaderSize(r, ChunkSize),
+		aead: aead,
+		buf:  header,
+		last: make([]byte, ChunkSize),
+	}, nil
+}
+
+// encryptReader 逐块加密的 Reader
+type encryptReader struct {
+	src   *bufio.Reader
+	aead  cipher.AEAD
+	index uint64
+	buf   []byte // 待输出的密文
+	out   []byte // 密文分块缓冲
+	last  []byte // 明文分块缓冲
+	done  bool
+	err   error
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fill 读取并加密下一个分块
+func (r *encryptReader) fill() {
+	n, err := io.ReadFull(r.src, r.last)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		r.err = err
+		return
+	}
+
+	// 预读一个字节判断是否为最后一块
+	final := n < ChunkSize
+	if !final {
+		if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+			final = true
+		} else if peekErr != nil {
+			r.err = peekErr
+			return
+		}
+	}
+
+	r.out = r.aead.Seal(r.out[:0], chunkNonce(r.index, final), r.last[:n], nil)
+	r.buf = r.out
+	r.index++
+	r.done = final
+}
+
+// NewDecryptReader 返回对 r 中完整密文进行流式解密的 Reader
+func (c *Cipher) NewDecryptReader(r io.Reader) (io.Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("读取加密文件头失败: %w", err)
+	}
+	if string(header[:len(fileMagic)]) != fileMagic {
+		return nil, errors.New("不是有效的加密文件")
+	}
+	if header[len(fileMagic)] != fileVersion {
+		return nil, fmt.Errorf("不支持的加密文件版本: %d", header[len(fileMagic)])
+	}
+
+	aead, err := c.fileAEAD(header[len(fileMagic)+1:])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		src:   bufio.NewReaderSize(r, chunkFull),
+		aead:  aead,
+		chunk: make([]byte, chunkFull),
+	}, nil
+}
+
+// decryptReader 逐块解密的 Reader
+type decryptReader struct {
+	src   *bufio.Reader
+	aead  cipher.AEAD
+	index uint64
+	chunk []byte
+	buf   []byte
+	done  bool
+	err   error
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fill 读取并解密下一个分块
+func (r *decryptReader) fill() {
+	n, err := io.ReadFull(r.src, r.chunk)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		r.err = err
+		return
+	}
+	if n < tagSize {
+		// 没有读到带最后一块标志的分块，说明密文被截断
+		r.err = fmt.Errorf("加密文件不完整: %w", ErrBadKey)
+		return
+	}
+
+	final := n < chunkFull
+	if !final {
+		if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+			final = true
+		} else if peekErr != nil {
+			r.err = peekErr
+			return
+		}
+	}
+
+	plain, openErr := r.aead.Open(r.chunk[:0], chunkNonce(r.index, final), r.chunk[:n], nil)
+	if openErr != nil {
+		r.err = ErrBadKey
+		return
+	}
+	r.buf = plain
+	r.index++
+	r.done = final
+}
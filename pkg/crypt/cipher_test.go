@@ -0,0 +1,114 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, key byte) *Cipher {
+	t.Helper()
+	c, err := NewCipher(bytes.Repeat([]byte{key}, 32), NamesOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func encrypt(t *testing.T, c *Cipher, plain []byte) []byte {
+	t.Helper()
+	r, err := c.NewEncryptReader(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+func decrypt(c *Cipher, enc []byte) ([]byte, error) {
+	r, err := c.NewDecryptReader(bytes.NewReader(enc))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, 1)
+	sizes := []int{0, 1, 100, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3 * ChunkSize, 3*ChunkSize + 17}
+	for _, size := range sizes {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		enc := encrypt(t, c, plain)
+		if got := EncryptedSize(int64(size)); got != int64(len(enc)) {
+			t.Errorf("EncryptedSize(%d) = %d, 实际密文长度 %d", size, got, len(enc))
+		}
+		if got, err := DecryptedSize(int64(len(enc))); err != nil || got != int64(size) {
+			t.Errorf("DecryptedSize(%d) = %d, %v, want %d", len(enc), got, err, size)
+		}
+
+		got, err := decrypt(c, enc)
+		if err != nil {
+			t.Fatalf("解密 %d 字节失败: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("%d 字节明文解密结果不一致", size)
+		}
+	}
+}
+
+func TestCipherTamper(t *testing.T) {
+	c := newTestCipher(t, 1)
+	plain := make([]byte, 2*ChunkSize+10)
+	rand.Read(plain)
+	enc := encrypt(t, c, plain)
+
+	flip := func(i int) func([]byte) []byte {
+		return func(b []byte) []byte {
+			b[i] ^= 1
+			return b
+		}
+	}
+	chunk1 := headerSize + chunkFull
+
+	tests := []struct {
+		name   string
+		modify func([]byte) []byte
+		cipher *Cipher
+		badKey bool
+	}{
+		{"文件nonce", flip(len(fileMagic) + 1), c, true},
+		{"第一块内容", flip(headerSize), c, true},
+		{"第一块认证标签", flip(headerSize + chunkFull - 1), c, true},
+		{"最后一块内容", flip(len(enc) - tagSize - 1), c, true},
+		{"截断最后一块", func(b []byte) []byte { return b[:headerSize+2*chunkFull] }, c, true},
+		{"截断到分块中间", func(b []byte) []byte { return b[:chunk1+100] }, c, true},
+		{"截断到只剩文件头", func(b []byte) []byte { return b[:headerSize] }, c, true},
+		{"交换分块", func(b []byte) []byte {
+			out := append([]byte{}, b[:headerSize]...)
+			out = append(out, b[chunk1:chunk1+chunkFull]...)
+			out = append(out, b[headerSize:chunk1]...)
+			return append(out, b[chunk1+chunkFull:]...)
+		}, c, true},
+		{"错误的密钥", func(b []byte) []byte { return b }, newTestCipher(t, 2), true},
+		{"错误的文件标识", flip(0), c, false},
+		{"不支持的版本", flip(len(fileMagic)), c, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decrypt(tt.cipher, tt.modify(append([]byte{}, enc...)))
+			if err == nil {
+				t.Fatal("被篡改的密文解密成功")
+			}
+			if tt.badKey && !errors.Is(err, ErrBadKey) {
+				t.Errorf("err = %v, want ErrBadKey", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NameMode 文件名和目录名的处理方式
+type NameMode string
+
+const (
+	// NamesOff 不处理文件名
+	NamesOff NameMode = "off"
+	// NamesEncrypt 使用确定性认证加密处理文件名，相同的名称总是得到相同的密文
+	NamesEncrypt NameMode = "encrypt"
+	// NamesObfuscate 只做可逆的字符轮换，名称长度不变，不能抵御有意的分析
+	NamesObfuscate NameMode = "obfuscate"
+)
+
+// maxNameLength 大多数服务器和文件系统允许的最大文件名长度
+const maxNameLength = 255
+
+// nameEncoding 小写的 base32hex 编码，在不区分大小写的服务器上也是安全的
+var nameEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv").WithPadding(base32.NoPadding)
+
+// ParseNameMode 解析文件名处理方式，空字符串视为 off
+func ParseNameMode(s string) (NameMode, error) {
+	switch NameMode(strings.ToLower(s)) {
+	case "", NamesOff:
+		return NamesOff, nil
+	case NamesEncrypt:
+		return NamesEncrypt, nil
+	case NamesObfuscate:
+		return NamesObfuscate, nil
+	default:
+		return "", fmt.Errorf("无效的文件名加密方式: %s (可选 off, encrypt, obfuscate)", s)
+	}
+}
+
+// EncryptPath 逐段处理以 / 分隔的路径
+func (c *Cipher) EncryptPath(p string) (string, error) {
+	if c.names == NamesOff {
+		return p, nil
+	}
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		enc, err := c.EncryptName(part)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = enc
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// DecryptPath 逐段还原以 / 分隔的路径
+func (c *Cipher) DecryptPath(p string) (string, error) {
+	if c.names == NamesOff {
+		return p, nil
+	}
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		dec, err := c.DecryptName(part)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = dec
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// EncryptName 处理单个文件名
+func (c *Cipher) EncryptName(name string) (string, error) {
+	var enc string
+	switch c.names {
+	case NamesEncrypt:
+		enc = c.sealName(name)
+	case NamesObfuscate:
+		enc = c.obfuscateName(name)
+	default:
+		return name, nil
+	}
+	if len(enc) > maxNameLength {
+		return "", fmt.Errorf("文件名 %q 加密后长度为 %d，超过 %d 的限制", name, len(enc), maxNameLength)
+	}
+	return enc, nil
+}
+
+// DecryptName 还原单个文件名
+func (c *Cipher) DecryptName(name string) (string, error) {
+	switch c.names {
+	case NamesEncrypt:
+		return c.openName(name)
+	case NamesObfuscate:
+		return c.deobfuscateName(name)
+	default:
+		return name, nil
+	}
+}
+
+// nameAEAD 返回文件名加密使用的 AES-GCM
+func (c *Cipher) nameAEAD() cipher.AEAD {
+	block, _ := aes.NewCipher(c.nameKey)
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+// sealName 以名称的 HMAC 作为合成 nonce（SIV 构造）进行确定性加密
+func (c *Cipher) sealName(name string) string {
+	mac := hmac.New(sha256.New, c.nameMacKey)
+	mac.Write([]byte(name))
+	nonce := mac.Sum(nil)[:12]
+
+	sealed := c.nameAEAD().Seal(nil, nonce, []byte(name), nil)
+	return nameEncoding.EncodeToString(append(nonce, sealed...))
+}
+
+// openName 解密并校验合成 nonce
+func (c *Cipher) openName(enc string) (string, error) {
+	data, err := nameEncoding.DecodeString(enc)
+	if err != nil || len(data) < 12+tagSize {
+		return "", fmt.Errorf("无法解密文件名 %q", enc)
+	}
+
+	nonce, sealed := data[:12], data[12:]
+	plain, err := c.nameAEAD().Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("无法解密文件名 %q: %w", enc, ErrBadKey)
+	}
+
+	mac := hmac.New(sha256.New, c.nameMacKey)
+	mac.Write(plain)
+	if !hmac.Equal(mac.Sum(nil)[:12], nonce) {
+		return "", fmt.Errorf("无法解密文件名 %q: %w", enc, ErrBadKey)
+	}
+	return string(plain), nil
+}
+
+// obfuscateName 按由密钥和名称决定的位移轮换字母和数字，格式为 "位移.轮换后的名称"
+func (c *Cipher) obfuscateName(name string) string {
+	shift := int(c.nameMacKey[0])
+	for _, r := range name {
+		shift += int(r)
+	}
+	shift %= 256
+
+	return strconv.Itoa(shift) + "." + rotate(name, shift)
+}
+
+// deobfuscateName 还原 obfuscateName 的结果
+func (c *Cipher) deobfuscateName(enc string) (string, error) {
+	prefix, rest, ok := strings.Cut(enc, ".")
+	shift, err := strconv.Atoi(prefix)
+	if !ok || err != nil || shift < 0 || shift > 255 {
+		return "", fmt.Errorf("无法还原文件名 %q", enc)
+	}
+	return rotate(rest, -shift), nil
+}
+
+// rotate 在各自的字符类别内轮换ASCII字母和数字
+func rotate(s string, shift int) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+			r = 'a' + rune(mod(int(r-'a')+shift, 26))
+		case r >= 'A' && r <= 'Z':
+			r = 'A' + rune(mod(int(r-'A')+shift, 26))
+		case r >= '0' && r <= '9':
+			r = '0' + rune(mod(int(r-'0')+shift, 10))
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// mod 返回非负的取模结果
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
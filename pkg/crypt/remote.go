@@ -0,0 +1,494 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+)
+
+// ParamsFile 服务器根目录下保存加密参数的文件，文件名本身不加密
+const ParamsFile = "/.sws-crypt.json"
+
+// keyCheckText 用于校验密钥是否正确的已知明文
+const keyCheckText = "SyncUsingWebDav key check"
+
+// params 加密参数，口令派生密钥使用的盐和密钥校验值，不包含任何密钥
+type params struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Check   []byte `json:"check"`
+}
+
+// Options 加密层配置
+type Options struct {
+	Passphrase string   // 口令，与 KeyFile 二选一
+	KeyFile    string   // 密钥文件路径
+	Names      NameMode // 文件名处理方式
+}
+
+// Remote 在另一个 client.Remote 之上透明地加密文件内容和文件名
+// 对调用方而言路径和文件大小都是明文形式
+type Remote struct {
+	inner  client.Remote
+	cipher *Cipher
+	issues atomic.Int64 // 无法解密而被跳过的列表条目数
+}
+
+var _ client.Remote = (*Remote)(nil)
+
+// Open 读取或初始化服务器上的加密参数，校验密钥后返回加密层
+func Open(inner client.Remote, opts Options) (*Remote, error) {
+	p, err := loadParams(inner)
+	if err != nil {
+		return nil, err
+	}
+	created := p == nil
+	if created {
+		p = &params{Version: 1, Salt: make([]byte, 16)}
+		if _, err := rand.Read(p.Salt); err != nil {
+			return nil, err
+		}
+	}
+
+	var masterKey []byte
+	switch {
+	case opts.KeyFile != "":
+		masterKey, err = LoadKeyFile(opts.KeyFile)
+	case opts.Passphrase != "":
+		masterKey, err = DeriveKey(opts.Passphrase, p.Salt)
+	default:
+		err = errors.New("启用加密时必须设置口令或密钥文件")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewCipher(masterKey, opts.Names)
+	if err != nil {
+		return nil, err
+	}
+
+	if created {
+		if p.Check, err = c.sealCheck(); err != nil {
+			return nil, err
+		}
+		if err := saveParams(inner, p); err != nil {
+			return nil, err
+		}
+	} else if !c.openCheck(p.Check) {
+		return nil, fmt.Errorf("加密密钥与服务器上已有的数据不匹配: %w", ErrBadKey)
+	}
+
+	return &Remote{inner: inner, cipher: c}, nil
+}
+
+// loadParams 读取服务器上的加密参数，不存在时返回 nil
+func loadParams(inner client.Remote) (*params, error) {
+	reader, err := inner.ReadStream(ParamsFile)
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取加密参数失败: %w", err)
+	}
+	defer reader.Close()
+
+	var p params
+	if err := json.NewDecoder(reader).Decode(&p); err != nil {
+		return nil, fmt.Errorf("解析加密参数失败: %w", err)
+	}
+	return &p, nil
+}
+
+// saveParams 将加密参数写入服务器
+func saveParams(inner client.Remote, p *params) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := inner.UploadStream(ParamsFile, bytes.NewReader(data), int64(len(data)), time.Now()); err != nil {
+		return fmt.Errorf("保存加密参数失败: %w", err)
+	}
+	return nil
+}
+
+// sealCheck 生成密钥校验值
+func (c *Cipher) sealCheck() ([]byte, error) {
+	aead, err := c.checkAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, []byte(keyCheckText), nil), nil
+}
+
+// openCheck 校验密钥
+func (c *Cipher) openCheck(check []byte) bool {
+	aead, err := c.checkAEAD()
+	if err != nil || len(check) < aead.NonceSize() {
+		return false
+	}
+	plain, err := aead.Open(nil, check[:aead.NonceSize()], check[aead.NonceSize():], nil)
+	return err == nil && string(plain) == keyCheckText
+}
+
+// SealValue 加密属性值，使用随机 nonce，结果以 base64 编码
+func (c *Cipher) SealValue(plain []byte) (string, error) {
+	aead, err := c.checkAEAD()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// OpenValue 解密 SealValue 生成的属性值
+func (c *Cipher) OpenValue(sealed string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("无法解密属性值: %w", err)
+	}
+	aead, err := c.checkAEAD()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize()+tagSize {
+		return nil, errors.New("无法解密属性值: 数据过短")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("无法解密属性值: 认证失败")
+	}
+	return plain, nil
+}
+
+// checkAEAD 密钥校验使用的 AES-GCM
+func (c *Cipher) checkAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.contentKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encPath 将明文路径转换为服务器上的路径
+func (r *Remote) encPath(p string) (string, error) {
+	enc, err := r.cipher.EncryptPath(p)
+	if err != nil {
+		return "", fmt.Errorf("加密路径 %s 失败: %w", p, err)
+	}
+	return enc, nil
+}
+
+// decInfo 将服务器返回的文件信息转换为明文形式
+func (r *Remote) decInfo(info client.FileInfo, plainPath string) (client.FileInfo, error) {
+	info.Path = plainPath
+	if !info.IsDir {
+		size, err := DecryptedSize(info.Size)
+		if err != nil {
+			return info, err
+		}
+		info.Size = size
+	}
+	return info, nil
+}
+
+// isParamsFile 判断服务器路径是否为加密参数文件
+func isParamsFile(p string) bool {
+	return "/"+strings.TrimPrefix(p, "/") == ParamsFile
+}
+
+// ListFiles 列出目录，返回明文文件名和明文大小；无法解密的条目会被跳过并给出警告
+func (r *Remote) ListFiles(remotePath string) ([]client.FileInfo, error) {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := r.inner.ListFiles(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []client.FileInfo
+	for _, file := range files {
+		if isParamsFile(file.Path) {
+			continue
+		}
+		name, err := r.cipher.DecryptName(path.Base(file.Path))
+		if err != nil {
+			log.Printf("警告: 跳过无法解密的远程条目 %s: %v", file.Path, err)
+			r.issues.Add(1)
+			continue
+		}
+		info, err := r.decInfo(file, path.Join("/", remotePath, name))
+		if err != nil {
+			log.Printf("警告: 跳过无法解密的远程条目 %s: %v", file.Path, err)
+			r.issues.Add(1)
+			continue
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// ListTree 递归列出目录树，返回明文路径和明文大小
+func (r *Remote) ListTree(remotePath string) ([]client.FileInfo, error) {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := r.inner.ListTree(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []client.FileInfo
+	for _, file := range files {
+		if isParamsFile(file.Path) {
+			continue
+		}
+		plainPath, err := r.cipher.DecryptPath(file.Path)
+		if err == nil {
+			file, err = r.decInfo(file, plainPath)
+		}
+		if err != nil {
+			log.Printf("警告: 跳过无法解密的远程条目 %s: %v", file.Path, err)
+			r.issues.Add(1)
+			continue
+		}
+		result = append(result, file)
+	}
+	return result, nil
+}
+
+// Stat 获取文件信息，返回明文大小
+func (r *Remote) Stat(remotePath string) (client.FileInfo, error) {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return client.FileInfo{}, err
+	}
+	info, err := r.inner.Stat(enc)
+	if err != nil {
+		return info, err
+	}
+	return r.decInfo(info, remotePath)
+}
+
+// FileExists 检查文件或目录是否存在
+func (r *Remote) FileExists(remotePath string) (bool, error) {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return false, err
+	}
+	return r.inner.FileExists(enc)
+}
+
+// MakeDir 创建目录
+func (r *Remote) MakeDir(remotePath string) error {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return err
+	}
+	return r.inner.MakeDir(enc)
+}
+
+// ReadStream 返回解密后的文件内容
+func (r *Remote) ReadStream(remotePath string) (io.ReadCloser, error) {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := r.inner.ReadStream(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := r.cipher.NewDecryptReader(reader)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("解密 %s 失败: %w", remotePath, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, reader}, nil
+}
+
+// DownloadFile 下载并解密文件到本地
+func (r *Remote) DownloadFile(remotePath, localPath string, modTime time.Time) error {
+	reader, err := r.ReadStream(remotePath)
+	if err != nil {
+		return fmt.Errorf("读取远程文件失败: %w", err)
+	}
+	defer reader.Close()
+
+	if err := client.WriteLocalFile(localPath, reader, modTime); err != nil {
+		return fmt.Errorf("解密 %s 失败: %w", remotePath, err)
+	}
+	return nil
+}
+
+// UploadFile 加密并上传本地文件
+func (r *Remote) UploadFile(localPath, remotePath string, modTime time.Time) (client.UploadResult, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return client.UploadResult{}, fmt.Errorf("获取本地文件信息失败: %w", err)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return client.UploadResult{}, fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer file.Close()
+
+	return r.UploadStream(remotePath, file, info.Size(), modTime)
+}
+
+// UploadStream 加密并上传数据流，size 为明文长度
+func (r *Remote) UploadStream(remotePath string, reader io.Reader, size int64, modTime time.Time) (client.UploadResult, error) {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return client.UploadResult{}, err
+	}
+	encrypted, err := r.cipher.NewEncryptReader(reader)
+	if err != nil {
+		return client.UploadResult{}, err
+	}
+	result, err := r.inner.UploadStream(enc, encrypted, EncryptedSize(size), modTime)
+	result.Size = size
+	return result, err
+}
+
+// Move 移动文件或目录
+func (r *Remote) Move(oldPath, newPath string, overwrite bool) error {
+	oldEnc, err := r.encPath(oldPath)
+	if err != nil {
+		return err
+	}
+	newEnc, err := r.encPath(newPath)
+	if err != nil {
+		return err
+	}
+	return r.inner.Move(oldEnc, newEnc, overwrite)
+}
+
+// Copy 复制文件或目录，密文可以直接复制，无需重新加密
+func (r *Remote) Copy(oldPath, newPath string, overwrite bool) error {
+	oldEnc, err := r.encPath(oldPath)
+	if err != nil {
+		return err
+	}
+	newEnc, err := r.encPath(newPath)
+	if err != nil {
+		return err
+	}
+	return r.inner.Copy(oldEnc, newEnc, overwrite)
+}
+
+// RemoveRemote 删除文件或目录
+func (r *Remote) RemoveRemote(remotePath string) error {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return err
+	}
+	return r.inner.RemoveRemote(enc)
+}
+
+// RemoveRemoteAll 递归删除目录
+func (r *Remote) RemoveRemoteAll(remotePath string) error {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return err
+	}
+	return r.inner.RemoveRemoteAll(enc)
+}
+
+// SetModTime 设置文件或目录的修改时间
+func (r *Remote) SetModTime(remotePath string, modTime time.Time) bool {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return false
+	}
+	return r.inner.SetModTime(enc, modTime)
+}
+
+// SetProperty 保存自定义属性，属性值加密后保存
+func (r *Remote) SetProperty(remotePath, name, value string) error {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return err
+	}
+	sealed, err := r.cipher.SealValue([]byte(value))
+	if err != nil {
+		return err
+	}
+	return r.inner.SetProperty(enc, name, sealed)
+}
+
+// Properties 读取目录及其直接子条目的自定义属性，返回明文路径和明文属性值
+// 无法解密的条目会被跳过并给出警告
+func (r *Remote) Properties(remotePath, name string) (map[string]string, error) {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	props, err := r.inner.Properties(enc, name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(props))
+	for p, sealed := range props {
+		plainPath, err := r.cipher.DecryptPath(p)
+		if err != nil {
+			log.Printf("警告: 跳过无法解密的远程属性 %s: %v", p, err)
+			continue
+		}
+		value, err := r.cipher.OpenValue(sealed)
+		if err != nil {
+			log.Printf("警告: 跳过无法解密的远程属性 %s: %v", plainPath, err)
+			continue
+		}
+		result[plainPath] = string(value)
+	}
+	return result, nil
+}
+
+// AllProperties 读取服务器上对应的加密路径的全部属性，属性值保持服务器上的原样
+func (r *Remote) AllProperties(remotePath string) ([]client.Property, error) {
+	enc, err := r.encPath(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	return r.inner.AllProperties(enc)
+}
+
+// Capabilities 返回底层服务器的能力
+func (r *Remote) Capabilities() *client.Capabilities {
+	return r.inner.Capabilities()
+}
+
+// ListIssues 返回底层列表问题与无法解密的条目数之和
+func (r *Remote) ListIssues() int64 {
+	return r.inner.ListIssues() + r.issues.Load()
+}
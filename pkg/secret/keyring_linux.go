@@ -0,0 +1,30 @@
+//go:build linux
+
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keyringLookup 通过 libsecret 的 secret-tool 从 Secret Service（GNOME Keyring、KWallet 等）读取密码
+// 密码需要事先用 secret-tool store --label=sws service <服务名> account <账户名> 保存
+func keyringLookup(service, account string) (string, error) {
+	cmd := exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("读取密钥环失败: 未找到 secret-tool，请安装 libsecret-tools")
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("读取密钥环失败 (service=%s account=%s): %s", service, account, msg)
+		}
+		return "", fmt.Errorf("密钥环中没有 service=%s account=%s 的密码", service, account)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
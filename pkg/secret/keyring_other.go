@@ -0,0 +1,10 @@
+//go:build !linux
+
+package secret
+
+import "errors"
+
+// keyringLookup 目前只支持 Linux 的 Secret Service
+func keyringLookup(service, account string) (string, error) {
+	return "", errors.New("keyring 凭据来源目前只支持 Linux 的 Secret Service，请改用 env、file 或 cmd")
+}
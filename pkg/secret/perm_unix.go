@@ -0,0 +1,21 @@
+//go:build !windows
+
+package secret
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkPrivate 检查密钥文件的权限，属组或其他用户有任何权限时拒绝读取
+func checkPrivate(path string, info os.FileInfo) error {
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("密钥文件 %s 的权限为 %04o，可以被其他用户访问，请执行 chmod 600 %s", path, perm, path)
+	}
+	return nil
+}
+
+// worldReadable 判断文件是否对其他用户可读
+func worldReadable(info os.FileInfo) bool {
+	return info.Mode().Perm()&0004 != 0
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package secret
+
+import "os"
+
+// checkPrivate 在Windows上文件访问由ACL控制，不检查权限位
+func checkPrivate(path string, info os.FileInfo) error {
+	return nil
+}
+
+// worldReadable 在Windows上无法通过权限位判断，始终返回 false
+func worldReadable(info os.FileInfo) bool {
+	return false
+}
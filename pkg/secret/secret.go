@@ -0,0 +1,146 @@
+// Package secret 从环境变量、密钥文件、外部命令或系统密钥环读取密码等凭据，
+// 使配置文件中只需保存凭据的来源而不是明文
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"SyncUsingWebDav/pkg/util"
+)
+
+// 凭据来源的前缀
+const (
+	SourceEnv     = "env"     // env:变量名，从环境变量读取
+	SourceFile    = "file"    // file:路径，从权限为 0600 的密钥文件读取
+	SourceCommand = "cmd"     // cmd:命令，运行外部命令（如密码管理器）并读取其输出的第一行
+	SourceKeyring = "keyring" // keyring:服务名/账户名，从系统密钥环读取
+)
+
+// DefaultKeyringService keyring 来源未指定服务名时使用的服务名
+const DefaultKeyringService = "sync-using-webdav"
+
+// commandTimeout 外部命令的最长运行时间，密码管理器可能需要用户交互解锁
+const commandTimeout = 2 * time.Minute
+
+// ErrEmpty 凭据来源返回了空值
+var ErrEmpty = errors.New("凭据为空")
+
+// Resolve 按来源引用读取凭据，account 为 keyring 来源未指定账户名时使用的账户名
+// 引用格式为 "来源:参数"，例如 env:SWS_PASSWORD、file:~/.config/sws/password、cmd:pass show webdav、keyring:sync-using-webdav/alice
+func Resolve(ref, account string) (string, error) {
+	kind, arg, err := parseRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	switch kind {
+	case SourceEnv:
+		v, found := os.LookupEnv(arg)
+		if !found {
+			return "", fmt.Errorf("环境变量 %s 未设置", arg)
+		}
+		value = v
+	case SourceFile:
+		value, err = readFile(arg)
+	case SourceCommand:
+		value, err = RunCommand(arg)
+	case SourceKeyring:
+		service, user, _ := strings.Cut(arg, "/")
+		if service == "" {
+			service = DefaultKeyringService
+		}
+		if user == "" {
+			user = account
+		}
+		value, err = keyringLookup(service, user)
+	}
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", fmt.Errorf("%s: %w", ref, ErrEmpty)
+	}
+	return value, nil
+}
+
+// CheckRef 只检查凭据来源引用的格式，不读取凭据
+func CheckRef(ref string) error {
+	_, _, err := parseRef(ref)
+	return err
+}
+
+// parseRef 将凭据来源引用拆分为来源类型和参数
+func parseRef(ref string) (kind, arg string, err error) {
+	kind, arg, ok := strings.Cut(ref, ":")
+	switch {
+	case kind == SourceKeyring:
+		return kind, arg, nil
+	case !ok:
+		return "", "", fmt.Errorf("无效的凭据来源 %q，格式应为 env:变量名、file:路径、cmd:命令 或 keyring:服务名/账户名", ref)
+	case kind != SourceEnv && kind != SourceFile && kind != SourceCommand:
+		return "", "", fmt.Errorf("未知的凭据来源类型 %q (可选 env, file, cmd, keyring)", kind)
+	case arg == "":
+		return "", "", fmt.Errorf("凭据来源 %q 缺少参数", ref)
+	}
+	return kind, arg, nil
+}
+
+// readFile 读取密钥文件，文件不能被属主以外的用户访问，内容结尾的换行符会被去掉
+func readFile(path string) (string, error) {
+	path = util.ExpandPath(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	if err := checkPrivate(path, info); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// RunCommand 通过系统 shell 运行外部命令，返回标准输出的第一行
+// 命令的标准输入和标准错误连接到终端，便于密码管理器提示解锁
+func RunCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("运行凭据命令失败: %w", err)
+	}
+
+	line, _, _ := strings.Cut(stdout.String(), "\n")
+	return strings.TrimRight(line, "\r"), nil
+}
+
+// WorldReadable 判断文件是否可以被所有用户读取，用于提示配置文件中的明文密码可能泄露
+// 在没有 POSIX 权限位的系统上始终返回 false
+func WorldReadable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return worldReadable(info)
+}
@@ -0,0 +1,138 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+)
+
+// deferDirTime 记录需要在本次运行最后设置修改时间的目录
+// 写入子条目和删除多余条目都会改变目录的修改时间，因此目录的修改时间在这些操作全部完成后统一设置
+// 恢复时 dir 为服务器上的目录信息；备份时 dir.LastModified 为本地目录的修改时间
+func (s *SyncManager) deferDirTime(dir client.FileInfo) {
+	s.dirsMu.Lock()
+	defer s.dirsMu.Unlock()
+	s.dirs = append(s.dirs, dir)
+}
+
+// setLocalDirTime 设置本地目录的修改时间，路径不是目录（包括符号链接）时跳过
+func setLocalDirTime(localPath string, modTime time.Time) error {
+	info, err := os.Lstat(localPath)
+	if err != nil || !info.IsDir() || sameModTime(info.ModTime(), modTime) {
+		return nil
+	}
+	return os.Chtimes(localPath, modTime, modTime)
+}
+
+// applyLocalDirTimes 恢复结束后将本地目录的修改时间设置为上传时记录的原始时间
+func (s *SyncManager) applyLocalDirTimes() {
+	for _, dir := range s.dirs {
+		modTime := s.state.LocalModTime(dir)
+		localPath, err := s.localPath(dir.Path)
+		if err != nil {
+			continue
+		}
+		if err := setLocalDirTime(localPath, modTime); err != nil {
+			log.Printf("警告: 设置目录修改时间失败 %s: %v", dir.Path, err)
+			continue
+		}
+		s.state.Set(dir.Path, &StateEntry{
+			LocalModTime:  modTime,
+			RemoteSize:    dir.Size,
+			RemoteModTime: dir.LastModified,
+		})
+	}
+}
+
+// applyRemoteDirTimes 备份结束后尝试将远程目录的修改时间设置为本地目录的修改时间
+// 服务器不允许修改时记录两侧的修改时间，恢复时据此还原本地目录的修改时间
+func (s *SyncManager) applyRemoteDirTimes() {
+	for _, dir := range s.dirs {
+		localModTime := dir.LastModified
+		info, err := s.client.Stat(dir.Path)
+		if err != nil {
+			log.Printf("警告: 获取远程目录信息失败 %s: %v", dir.Path, err)
+			continue
+		}
+		if entry, ok := s.state.Get(dir.Path); ok &&
+			sameModTime(entry.LocalModTime, localModTime) && sameModTime(entry.RemoteModTime, info.LastModified) {
+			continue
+		}
+
+		remoteModTime := info.LastModified
+		if s.client.SetModTime(dir.Path, localModTime) {
+			remoteModTime = localModTime
+		}
+		s.state.Set(dir.Path, &StateEntry{
+			LocalModTime:  localModTime,
+			RemoteSize:    info.Size,
+			RemoteModTime: remoteModTime,
+		})
+	}
+}
+
+// ensureRemoteDir 确保远程目录存在，服务器上同名的是文件时先删除该文件（启用历史版本时保存旧版本）
+func (s *SyncManager) ensureRemoteDir(remotePath string) error {
+	info, err := s.client.Stat(remotePath)
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+	case err != nil:
+		return fmt.Errorf("检查远程目录失败 %s: %w", remotePath, err)
+	case info.IsDir:
+		return nil
+	default:
+		log.Printf("远程同名文件将被替换为目录: %s", remotePath)
+		if err := s.removeRemoteVersioned(remotePath); err != nil {
+			return fmt.Errorf("删除远程文件失败 %s: %w", remotePath, err)
+		}
+		s.state.Remove(remotePath)
+	}
+
+	log.Printf("创建远程目录: %s", remotePath)
+	if err := s.client.MakeDir(remotePath); err != nil {
+		return fmt.Errorf("创建远程目录失败 %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// replaceRemoteDir 删除将被同名文件替换的远程目录，启用历史版本时先保存目录中每个文件的旧版本
+func (s *SyncManager) replaceRemoteDir(remotePath string) error {
+	log.Printf("远程同名目录将被替换为文件: %s", remotePath)
+	if s.config.Versioning {
+		entries, err := s.walkRemote(remotePath)
+		if err != nil {
+			return fmt.Errorf("列出远程目录失败 %s: %w", remotePath, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir {
+				continue
+			}
+			if err := s.archiveVersion(entry.Path); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.client.RemoveRemoteAll(remotePath); err != nil {
+		return fmt.Errorf("删除远程目录失败 %s: %w", remotePath, err)
+	}
+	s.state.Remove(remotePath)
+	return nil
+}
+
+// ensureLocalDir 确保本地目录存在，本地同名的是文件时先删除该文件（启用回收站时移入回收站）
+func (s *SyncManager) ensureLocalDir(localPath, relPath string) error {
+	if info, err := os.Stat(localPath); err == nil && !info.IsDir() {
+		log.Printf("本地同名文件将被替换为目录: %s", relPath)
+		if err := s.removeLocal(localPath, relPath); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(localPath, 0755); err != nil {
+		return fmt.Errorf("创建本地目录 %s 失败: %w", localPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/util"
+)
+
+// GetFile 下载单个远程文件到指定的本地路径，localPath 为已存在的目录时下载到该目录中
+// 本地修改时间恢复为上传时记录的原始时间，不更新同步状态
+func (s *SyncManager) GetFile(remotePath, localPath string) error {
+	remotePath = "/" + relKey(remotePath)
+	info, err := s.client.Stat(remotePath)
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return fmt.Errorf("远程文件不存在: %s", remotePath)
+		}
+		return err
+	}
+	if info.IsDir {
+		return fmt.Errorf("%s 是目录，请使用 restore 命令恢复目录", remotePath)
+	}
+
+	if err := s.loadState(); err != nil {
+		return err
+	}
+	if st, err := os.Stat(localPath); err == nil && st.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+
+	log.Printf("下载文件: %s -> %s (大小: %s)", remotePath, localPath, util.FormatSize(info.Size))
+	localModTime := s.state.LocalModTime(info)
+	return util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+		return s.client.DownloadFile(remotePath, localPath, localModTime)
+	})
+}
+
+// PutFile 上传单个本地文件到指定的远程路径，remotePath 以 / 结尾或为已存在的远程目录时上传到该目录中
+// 覆盖已有文件时按配置保存历史版本，并记录同步状态
+func (s *SyncManager) PutFile(localPath, remotePath string) error {
+	localInfo, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("获取本地文件信息失败: %w", err)
+	}
+	if !localInfo.Mode().IsRegular() {
+		return fmt.Errorf("%s 不是普通文件", localPath)
+	}
+
+	intoDir := remotePath == "" || strings.HasSuffix(remotePath, "/")
+	remotePath = "/" + relKey(remotePath)
+	exists := false
+	if info, err := s.client.Stat(remotePath); err == nil {
+		intoDir = intoDir || info.IsDir
+		exists = !info.IsDir
+	} else if !errors.Is(err, client.ErrNotFound) {
+		return err
+	}
+	if intoDir {
+		remotePath = path.Join(remotePath, filepath.Base(localPath))
+		exists, err = s.client.FileExists(remotePath)
+		if err != nil {
+			return err
+		}
+	}
+	if isReserved(remotePath) {
+		return fmt.Errorf("不能上传到保留目录: %s", remotePath)
+	}
+
+	s.runStart = time.Now()
+	if err := s.loadState(); err != nil {
+		return err
+	}
+	defer s.saveState()
+
+	if exists && s.config.Versioning {
+		if err := s.saveVersion(remotePath); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("上传文件: %s -> %s (大小: %s)", localPath, remotePath, util.FormatSize(localInfo.Size()))
+	var result client.UploadResult
+	err = util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+		var err error
+		result, err = s.client.UploadFile(localPath, remotePath, localInfo.ModTime())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.recordUpload(remotePath, localInfo, result)
+	return nil
+}
+
+// Remove 删除远程文件或目录，删除目录需要 recursive 为 true
+// 启用历史版本时文件会移动到历史版本目录
+func (s *SyncManager) Remove(paths []string, recursive bool) error {
+	if len(paths) == 0 {
+		return errors.New("没有指定要删除的路径")
+	}
+
+	s.runStart = time.Now()
+	if err := s.loadState(); err != nil {
+		return err
+	}
+	defer s.saveState()
+
+	var errs []error
+	for _, p := range paths {
+		remotePath := "/" + relKey(p)
+		if remotePath == "/" || isReserved(remotePath) {
+			errs = append(errs, fmt.Errorf("不能删除根目录或保留目录: %s", p))
+			continue
+		}
+		info, err := s.client.Stat(remotePath)
+		if err != nil {
+			if errors.Is(err, client.ErrNotFound) {
+				err = fmt.Errorf("远程路径不存在: %s", remotePath)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if info.IsDir && !recursive {
+			errs = append(errs, fmt.Errorf("%s 是目录，删除目录需要 -r 参数", remotePath))
+			continue
+		}
+
+		log.Printf("删除: %s", remotePath)
+		if err := s.removeRemoteTree(info); err != nil {
+			errs = append(errs, fmt.Errorf("删除 %s 失败: %w", remotePath, err))
+			continue
+		}
+		s.state.Remove(remotePath)
+	}
+	return errors.Join(errs...)
+}
+
+// removeRemoteTree 删除远程文件或整个目录，启用历史版本时先逐个移走目录中的文件
+func (s *SyncManager) removeRemoteTree(info client.FileInfo) error {
+	if !info.IsDir || !s.config.Versioning {
+		if info.IsDir {
+			return s.client.RemoveRemoteAll(info.Path)
+		}
+		return s.removeRemoteVersioned(info.Path)
+	}
+
+	entries, err := s.walkRemote(info.Path)
+	if err != nil {
+		return err
+	}
+	// 按照路径长度降序排序，确保先删除子文件和子目录
+	sort.Slice(entries, func(i, j int) bool {
+		return len(entries[i].Path) > len(entries[j].Path)
+	})
+	for _, entry := range entries {
+		if err := s.removeRemoteVersioned(entry.Path); err != nil {
+			return err
+		}
+	}
+	return s.removeRemoteVersioned(info.Path)
+}
@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// minGuardedDeletes 删除条目数不超过该值时不检查删除比例，避免小目录中删除少量文件也被阻止
+const minGuardedDeletes = 10
+
+// ErrDeleteBlocked 删除操作被安全检查阻止
+var ErrDeleteBlocked = errors.New("删除操作已被安全检查阻止")
+
+// checkDeletions 在删除目标位置多余的条目前进行安全检查
+// source 为源位置的条目列表，target 为目标位置的条目列表，toDelete 为将要删除的条目
+// 源位置为空、本次运行中目录列表可能不完整、删除数量或比例超过配置的上限时阻止删除，除非指定了 -force-delete
+func (s *SyncManager) checkDeletions(toDelete, source, target []string) error {
+	if len(toDelete) == 0 {
+		return nil
+	}
+
+	var reasons []string
+	if len(source) == 0 {
+		reasons = append(reasons, "源位置为空，可能是地址配置错误、共享已失效或目录暂时不可读")
+	}
+	if issues := s.client.ListIssues() - s.listIssues; issues > 0 {
+		reasons = append(reasons, fmt.Sprintf("本次运行中有 %d 次远程目录列表可能不完整", issues))
+	}
+	if limit := s.config.DeleteMaxCount; limit > 0 && len(toDelete) > limit {
+		reasons = append(reasons, fmt.Sprintf("将删除 %d 个条目，超过 delete_max_count = %d", len(toDelete), limit))
+	}
+	if limit := s.config.DeleteMaxPercent; limit > 0 && len(toDelete) > minGuardedDeletes && len(target) > 0 {
+		if percent := len(toDelete) * 100 / len(target); percent > limit {
+			reasons = append(reasons, fmt.Sprintf("将删除目标位置 %d%% 的条目 (%d/%d)，超过 delete_max_percent = %d",
+				percent, len(toDelete), len(target), limit))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+	if s.config.ForceDelete {
+		log.Printf("警告: 已指定 -force-delete，忽略删除安全检查: %s", strings.Join(reasons, "；"))
+		return nil
+	}
+	return fmt.Errorf("%w: %s；确认无误后可使用 -force-delete 强制删除", ErrDeleteBlocked, strings.Join(reasons, "；"))
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/config"
+)
+
+// issueRemote 只提供 ListIssues 的远程存储
+type issueRemote struct {
+	client.Remote
+	issues int64
+}
+
+func (r *issueRemote) ListIssues() int64 { return r.issues }
+
+func entries(n int) []string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("f%d", i)
+	}
+	return list
+}
+
+func TestCheckDeletions(t *testing.T) {
+	tests := []struct {
+		name       string
+		toDelete   int
+		source     int
+		target     int
+		maxCount   int
+		maxPercent int
+		issues     int64
+		force      bool
+		blocked    bool
+	}{
+		{"没有要删除的条目", 0, 0, 100, 10, 10, 5, false, false},
+		{"正常删除", 5, 95, 100, 10, 50, 0, false, false},
+		{"源位置为空", 1, 0, 1, 0, 0, 0, false, true},
+		{"列表不完整", 1, 99, 100, 0, 0, 1, false, true},
+		{"等于数量上限", 10, 90, 100, 10, 0, 0, false, false},
+		{"超过数量上限", 11, 89, 100, 10, 0, 0, false, true},
+		{"数量上限为0不检查", 1000, 1, 1001, 0, 0, 0, false, false},
+		{"比例超过上限", 60, 40, 100, 0, 50, 0, false, true},
+		{"比例等于上限", 50, 50, 100, 0, 50, 0, false, false},
+		{"少量删除不检查比例", minGuardedDeletes, 1, minGuardedDeletes + 1, 0, 10, 0, false, false},
+		{"超过少量删除时检查比例", minGuardedDeletes + 1, 1, minGuardedDeletes + 2, 0, 10, 0, false, true},
+		{"比例上限为0不检查", 99, 1, 100, 0, 0, 0, false, false},
+		{"强制删除", 100, 0, 100, 10, 10, 3, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				DeleteMaxCount:   tt.maxCount,
+				DeleteMaxPercent: tt.maxPercent,
+				ForceDelete:      tt.force,
+			}
+			s := &SyncManager{client: &issueRemote{issues: tt.issues}, config: cfg}
+
+			err := s.checkDeletions(entries(tt.toDelete), entries(tt.source), entries(tt.target))
+			if blocked := errors.Is(err, ErrDeleteBlocked); blocked != tt.blocked {
+				t.Errorf("checkDeletions() = %v, want blocked=%v", err, tt.blocked)
+			}
+		})
+	}
+}
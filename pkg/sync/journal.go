@@ -0,0 +1,591 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"SyncUsingWebDav/pkg/util"
+)
+
+// 本地索引文件名及格式版本
+const (
+	journalFileName = "journal.json"
+	journalVersion  = 1
+)
+
+// JournalEntry 本地索引中的一个条目，记录上次成功备份时的文件状态
+type JournalEntry struct {
+	Path    string    `json:"path"`
+	Name    string    `json:"name,omitempty"` // 本地名称，与服务器上的名称不同时记录
+	IsDir   bool      `json:"is_dir,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Inode   uint64    `json:"inode,omitempty"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// journalFile 本地索引的磁盘格式
+type journalFile struct {
+	Version  int             `json:"version"`
+	Symlinks string          `json:"symlinks,omitempty"`
+	Names    string          `json:"names,omitempty"`
+	Entries  []*JournalEntry `json:"entries"`
+}
+
+// Journal 持久化的本地变更索引
+// 路径均为相对于本地同步目录的斜杠风格路径，根目录使用空字符串
+type Journal struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]*JournalEntry
+
+	// symlinks 和 names 建立索引时使用的符号链接处理策略和名称转换设置，变化后不能直接使用索引中的子条目列表
+	symlinks string
+	names    string
+}
+
+// Rename 描述一次本地重命名或移动，也用于描述可在服务端复制的重复内容
+type Rename struct {
+	From  string
+	To    string
+	IsDir bool
+
+	// Covered 目录重命名后无需再上传的子文件（内容与旧路径下的文件一致）
+	Covered []string
+}
+
+// ChangeSet 一次扫描得到的本地变更集合
+type ChangeSet struct {
+	Current  map[string]*JournalEntry // 当前本地所有条目（包含根目录 ""）
+	Added    []string                 // 新增的文件
+	Modified []string                 // 内容或属性变化的文件
+	Removed  []string                 // 索引中存在但本地已不存在的条目
+	Renames  []Rename                 // 检测到的重命名/移动
+	Copies   []Rename                 // 内容与已备份文件相同、可在服务端复制的文件
+
+	changed map[string]bool // 需要上传的文件
+	dirty   map[string]bool // 子树中存在变更的目录
+}
+
+// LoadJournal 从文件加载本地索引，文件不存在时返回空索引
+func LoadJournal(path string) (*Journal, error) {
+	j := &Journal{
+		path:    path,
+		entries: make(map[string]*JournalEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return nil, err
+	}
+
+	var file journalFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析本地索引失败: %v", err)
+	}
+	if file.Version != journalVersion {
+		// 格式不兼容时丢弃旧索引，下一次备份会重新建立
+		return j, nil
+	}
+
+	for _, entry := range file.Entries {
+		j.entries[entry.Path] = entry
+	}
+	j.symlinks = file.Symlinks
+	j.names = file.Names
+	return j, nil
+}
+
+// Save 将本地索引原子性地写回磁盘
+func (j *Journal) Save() error {
+	j.mu.Lock()
+	file := journalFile{Version: journalVersion, Symlinks: j.symlinks, Names: j.names}
+	for _, entry := range j.entries {
+		file.Entries = append(file.Entries, entry)
+	}
+	j.mu.Unlock()
+
+	sort.Slice(file.Entries, func(i, k int) bool {
+		return file.Entries[i].Path < file.Entries[k].Path
+	})
+
+	data, err := json.Marshal(&file)
+	if err != nil {
+		return fmt.Errorf("序列化本地索引失败: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return fmt.Errorf("创建状态目录失败: %v", err)
+	}
+
+	tmpFile := j.path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("写入本地索引失败: %v", err)
+	}
+	return os.Rename(tmpFile, j.path)
+}
+
+// Record 记录一个已成功备份的条目
+func (j *Journal) Record(entry *JournalEntry) {
+	if entry == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[entry.Path] = entry
+}
+
+// Forget 从索引中移除指定路径及其所有子路径
+func (j *Journal) Forget(p string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	prefix := p + "/"
+	for key := range j.entries {
+		if key == p || strings.HasPrefix(key, prefix) {
+			delete(j.entries, key)
+		}
+	}
+}
+
+// Scan 扫描本地目录并与索引比较，得到变更集合
+// 目录修改时间与索引一致时直接使用索引中的子条目列表，避免重新读取目录
+// 符号链接和文件名按 names 处理，条目路径与服务器上的路径一致
+func (j *Journal) Scan(root string, names *nameMapper) (*ChangeSet, error) {
+	cs := &ChangeSet{
+		Current: make(map[string]*JournalEntry),
+		changed: make(map[string]bool),
+		dirty:   make(map[string]bool),
+	}
+
+	// 建立父目录到子条目本地名称的映射
+	children := make(map[string][]string)
+	for key, entry := range j.entries {
+		if key == "" {
+			continue
+		}
+		parent := path.Dir(key)
+		if parent == "." {
+			parent = ""
+		}
+		name := entry.Name
+		if name == "" {
+			name = path.Base(key)
+		}
+		children[parent] = append(children[parent], name)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	cs.Current[""] = newJournalEntry("", info)
+
+	if j.symlinks != names.symlinks || j.names != names.signature() {
+		children = nil // 符号链接策略或名称转换设置变化，重新读取所有目录
+		j.symlinks = names.symlinks
+		j.names = names.signature()
+	}
+	if _, err := j.scanDir(root, "", "", info, children, names, cs); err != nil {
+		return nil, err
+	}
+
+	for key := range j.entries {
+		if key == "" {
+			continue
+		}
+		if _, ok := cs.Current[key]; !ok {
+			cs.Removed = append(cs.Removed, key)
+		}
+	}
+	sort.Strings(cs.Added)
+	sort.Strings(cs.Modified)
+	sort.Strings(cs.Removed)
+
+	j.detectRenames(cs)
+	return cs, nil
+}
+
+// scanDir 扫描单个目录，返回该目录的子树中是否存在变更
+// localRel 为目录的本地相对路径，rel 为目录在服务器上的相对路径
+func (j *Journal) scanDir(root, localRel, rel string, dirInfo os.FileInfo, children map[string][]string, mapper *nameMapper, cs *ChangeSet) (bool, error) {
+	dirPath := filepath.Join(root, filepath.FromSlash(localRel))
+	dirty := false
+
+	var names []string
+	prev, known := j.entries[rel]
+	if known && prev.IsDir && prev.ModTime.Equal(dirInfo.ModTime()) && children != nil {
+		// 目录项未发生增删，直接使用索引中的子条目
+		names = children[rel]
+	} else {
+		entries, err := os.ReadDir(dirPath)
+		if err != nil {
+			return false, fmt.Errorf("读取本地目录失败 %s: %v", dirPath, err)
+		}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		dirty = true
+	}
+
+	var locals []*localEntry
+	for _, name := range names {
+		local, err := resolveLocal(root, localRel, name, mapper)
+		if err != nil {
+			if os.IsNotExist(err) {
+				dirty = true
+				continue
+			}
+			return false, fmt.Errorf("获取本地文件信息失败: %v", err)
+		}
+		if local != nil {
+			locals = append(locals, local)
+		}
+	}
+
+	for _, local := range mapper.dropLocalCollisions(localRel, locals) {
+		childRel := path.Join(rel, local.RemoteName())
+		childPath := filepath.Join(root, filepath.FromSlash(local.Path))
+		info := local.Info
+
+		entry := newJournalEntry(childRel, info)
+		if local.Name != path.Base(childRel) {
+			entry.Name = local.Name
+		}
+		if local.Link != "" {
+			entry.Size = int64(len(local.Link))
+		}
+		cs.Current[childRel] = entry
+
+		if local.Link == "" && info.IsDir() {
+			subDirty, err := j.scanDir(root, local.Path, childRel, info, children, mapper, cs)
+			if err != nil {
+				return false, err
+			}
+			if subDirty {
+				dirty = true
+			}
+			continue
+		}
+
+		old, ok := j.entries[childRel]
+		if ok && !old.IsDir && old.Size == entry.Size && old.ModTime.Equal(entry.ModTime) && old.Inode == entry.Inode {
+			entry.Hash = old.Hash
+			continue
+		}
+
+		// 只为变化的文件计算摘要，用于重命名检测
+		if local.Link != "" {
+			entry.Hash = linkHash(local.Link)
+		} else {
+			hash, err := util.HashFile(childPath)
+			if err != nil {
+				return false, fmt.Errorf("计算文件摘要失败 %s: %v", childPath, err)
+			}
+			entry.Hash = hash
+		}
+
+		cs.changed[childRel] = true
+		if ok {
+			cs.Modified = append(cs.Modified, childRel)
+		} else {
+			cs.Added = append(cs.Added, childRel)
+		}
+		dirty = true
+	}
+
+	if dirty {
+		cs.dirty[rel] = true
+	}
+	return dirty, nil
+}
+
+// detectRenames 将新增条目与已消失的条目配对，识别重命名和重复内容
+// 目录按inode配对，整个目录只需一次服务端移动；文件按inode或大小加内容摘要配对
+func (j *Journal) detectRenames(cs *ChangeSet) {
+	claimed := make(map[string]bool)  // 已被配对的旧路径
+	resolved := make(map[string]bool) // 已确定同步方式的新路径
+
+	if len(cs.Removed) > 0 {
+		j.detectDirRenames(cs, claimed, resolved)
+		j.detectFileRenames(cs, claimed, resolved)
+	}
+	j.detectCopies(cs, resolved)
+}
+
+// detectDirRenames 识别目录重命名，并找出随目录一起移动且未变化的子文件
+func (j *Journal) detectDirRenames(cs *ChangeSet, claimed, resolved map[string]bool) {
+	removedDirs := make(map[uint64]*JournalEntry)
+	for _, key := range cs.Removed {
+		if old := j.entries[key]; old.IsDir && old.Inode != 0 {
+			removedDirs[old.Inode] = old
+		}
+	}
+	if len(removedDirs) == 0 {
+		return
+	}
+
+	var newDirs []string
+	for key, entry := range cs.Current {
+		if old, ok := j.entries[key]; key != "" && entry.IsDir && (!ok || !old.IsDir) {
+			newDirs = append(newDirs, key)
+		}
+	}
+	// 父目录排在子目录之前，已随父目录移动的子目录不再单独处理
+	sort.Strings(newDirs)
+
+	var renamed []string
+	for _, key := range newDirs {
+		if isUnderAny(key, renamed) {
+			continue
+		}
+		entry := cs.Current[key]
+		old, ok := removedDirs[entry.Inode]
+		if !ok || entry.Inode == 0 || claimed[old.Path] {
+			continue
+		}
+
+		rename := Rename{From: old.Path, To: key, IsDir: true}
+		prefix := key + "/"
+		for childKey, child := range cs.Current {
+			if child.IsDir || !strings.HasPrefix(childKey, prefix) {
+				continue
+			}
+			oldChild, ok := j.entries[old.Path+"/"+strings.TrimPrefix(childKey, prefix)]
+			if ok && sameContent(oldChild, child) {
+				rename.Covered = append(rename.Covered, childKey)
+				claimed[oldChild.Path] = true
+				resolved[childKey] = true
+			}
+		}
+		sort.Strings(rename.Covered)
+
+		claimed[old.Path] = true
+		renamed = append(renamed, key)
+		cs.Renames = append(cs.Renames, rename)
+	}
+}
+
+// detectFileRenames 识别单个文件的重命名或移动
+func (j *Journal) detectFileRenames(cs *ChangeSet, claimed, resolved map[string]bool) {
+	byInode := make(map[uint64]*JournalEntry)
+	byHash := make(map[string]*JournalEntry)
+	for _, key := range cs.Removed {
+		old := j.entries[key]
+		if old.IsDir || claimed[key] {
+			continue
+		}
+		if old.Inode != 0 {
+			byInode[old.Inode] = old
+		}
+		if old.Hash != "" {
+			byHash[old.Hash] = old
+		}
+	}
+
+	for _, key := range cs.Added {
+		if resolved[key] {
+			continue
+		}
+		entry := cs.Current[key]
+
+		var match *JournalEntry
+		if old, ok := byInode[entry.Inode]; ok && entry.Inode != 0 && sameContent(old, entry) {
+			match = old
+		} else if old, ok := byHash[entry.Hash]; ok && old.Size == entry.Size {
+			match = old
+		}
+
+		if match == nil || claimed[match.Path] {
+			continue
+		}
+		claimed[match.Path] = true
+		resolved[key] = true
+		cs.Renames = append(cs.Renames, Rename{From: match.Path, To: key})
+	}
+}
+
+// detectCopies 为内容与某个未变化的已备份文件相同的新增或修改文件安排服务端复制
+func (j *Journal) detectCopies(cs *ChangeSet, resolved map[string]bool) {
+	byHash := make(map[string]*JournalEntry)
+	for key, entry := range cs.Current {
+		if entry.IsDir || entry.Hash == "" || cs.changed[key] {
+			continue
+		}
+		if _, ok := j.entries[key]; ok {
+			byHash[entry.Hash] = entry
+		}
+	}
+	if len(byHash) == 0 {
+		return
+	}
+
+	for _, keys := range [][]string{cs.Added, cs.Modified} {
+		for _, key := range keys {
+			if resolved[key] {
+				continue
+			}
+			entry := cs.Current[key]
+			if source, ok := byHash[entry.Hash]; ok && source.Size == entry.Size && entry.Size > 0 {
+				resolved[key] = true
+				cs.Copies = append(cs.Copies, Rename{From: source.Path, To: key})
+			}
+		}
+	}
+}
+
+// sameContent 判断两个文件条目的大小和修改时间是否一致
+func sameContent(a, b *JournalEntry) bool {
+	return !a.IsDir && !b.IsDir && a.Size == b.Size && a.ModTime.Equal(b.ModTime)
+}
+
+// isUnderAny 判断路径是否位于任一给定目录之下
+func isUnderAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Paths 返回当前本地所有条目的相对路径（不含根目录）
+func (cs *ChangeSet) Paths() []string {
+	paths := make([]string, 0, len(cs.Current))
+	for key := range cs.Current {
+		if key != "" {
+			paths = append(paths, key)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Unchanged 判断条目自上次备份以来是否未变化
+// 对目录而言，只有整个子树都未变化时才返回 true
+func (cs *ChangeSet) Unchanged(p string) bool {
+	entry, ok := cs.Current[p]
+	if !ok {
+		return false
+	}
+	if entry.IsDir {
+		return !cs.dirty[p]
+	}
+	return !cs.changed[p]
+}
+
+// MarkSynced 标记文件已通过其他方式（如服务端移动）同步，无需再上传
+func (cs *ChangeSet) MarkSynced(p string) {
+	delete(cs.changed, p)
+}
+
+// newJournalEntry 根据本地文件信息创建索引条目
+func newJournalEntry(p string, info os.FileInfo) *JournalEntry {
+	entry := &JournalEntry{
+		Path:    p,
+		IsDir:   info.IsDir(),
+		ModTime: info.ModTime(),
+	}
+	if !info.IsDir() {
+		entry.Size = info.Size()
+	}
+	if _, ino, ok := util.FileID(info); ok {
+		entry.Inode = ino
+	}
+	return entry
+}
+
+// loadJournal 加载本地索引并扫描本地变更
+func (s *SyncManager) loadJournal() error {
+	journal, err := LoadJournal(s.config.StatePath(journalFileName))
+	if err != nil {
+		return fmt.Errorf("加载本地索引失败: %v", err)
+	}
+
+	changes, err := journal.Scan(s.config.LocalDir, s.names)
+	if err != nil {
+		return fmt.Errorf("扫描本地变更失败: %v", err)
+	}
+
+	s.journal = journal
+	s.changes = changes
+	return nil
+}
+
+// saveJournal 清理已消失的条目并保存本地索引
+func (s *SyncManager) saveJournal() {
+	for _, key := range s.changes.Removed {
+		s.journal.Forget(key)
+	}
+	if err := s.journal.Save(); err != nil {
+		log.Printf("警告: 保存本地索引失败: %v", err)
+	}
+}
+
+// recordJournal 将本次扫描得到的条目记录为已备份
+func (s *SyncManager) recordJournal(key string) {
+	if s.journal == nil {
+		return
+	}
+	s.journal.Record(s.changes.Current[key])
+}
+
+// replayRenames 将本地检测到的重命名和重复内容在服务端重放，避免重新上传
+// 启用删除同步时重命名使用 MOVE，否则旧路径上的远程文件需要保留，改用 COPY
+func (s *SyncManager) replayRenames() {
+	for _, rename := range s.changes.Renames {
+		from := "/" + rename.From
+		to := "/" + rename.To
+
+		var err error
+		if s.config.SyncDelete {
+			log.Printf("远程移动: %s -> %s", from, to)
+			err = s.client.Move(from, to, false)
+		} else {
+			log.Printf("远程复制: %s -> %s", from, to)
+			err = s.client.Copy(from, to, false)
+		}
+		if err != nil {
+			log.Printf("警告: 服务端重命名失败，将重新上传: %v", err)
+			continue
+		}
+		if s.config.SyncDelete {
+			s.state.Rename(from, to)
+		}
+
+		if !rename.IsDir {
+			s.markSynced(rename.To)
+		}
+		for _, key := range rename.Covered {
+			s.markSynced(key)
+		}
+	}
+
+	for _, dup := range s.changes.Copies {
+		from := "/" + dup.From
+		to := "/" + dup.To
+		log.Printf("远程复制重复内容: %s -> %s", from, to)
+		// 启用版本保留时不覆盖已有文件，已有文件交给上传流程先保存历史版本
+		if err := s.client.Copy(from, to, !s.config.Versioning); err != nil {
+			log.Printf("警告: 服务端复制失败，将重新上传: %v", err)
+			continue
+		}
+		s.markSynced(dup.To)
+	}
+}
+
+// markSynced 标记文件已在服务端同步并记入索引
+func (s *SyncManager) markSynced(key string) {
+	s.changes.MarkSynced(key)
+	s.recordJournal(key)
+}
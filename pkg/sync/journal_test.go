@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestJournalScan 保存索引后修改本地目录，重新加载索引扫描得到的变更集合
+func TestJournalScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(t *testing.T, root string)
+		added    []string
+		modified []string
+		removed  []string
+		renames  []Rename
+		copies   []Rename
+	}{
+		{
+			name:   "未变化",
+			change: func(t *testing.T, root string) {},
+		},
+		{
+			name: "修改文件",
+			change: func(t *testing.T, root string) {
+				writeFile(t, filepath.Join(root, "a", "f.txt"), "changed")
+			},
+			modified: []string{"a/f.txt"},
+		},
+		{
+			name: "新增文件",
+			change: func(t *testing.T, root string) {
+				writeFile(t, filepath.Join(root, "a", "new.txt"), "new")
+			},
+			added: []string{"a/new.txt"},
+		},
+		{
+			name: "删除文件",
+			change: func(t *testing.T, root string) {
+				remove(t, filepath.Join(root, "top.txt"))
+			},
+			removed: []string{"top.txt"},
+		},
+		{
+			name: "重命名文件",
+			change: func(t *testing.T, root string) {
+				rename(t, filepath.Join(root, "top.txt"), filepath.Join(root, "a", "moved.txt"))
+			},
+			added:   []string{"a/moved.txt"},
+			removed: []string{"top.txt"},
+			renames: []Rename{{From: "top.txt", To: "a/moved.txt"}},
+		},
+		{
+			name: "重命名目录",
+			change: func(t *testing.T, root string) {
+				rename(t, filepath.Join(root, "a"), filepath.Join(root, "c"))
+			},
+			added:   []string{"c/f.txt"},
+			removed: []string{"a", "a/f.txt"},
+			renames: []Rename{{From: "a", To: "c", IsDir: true, Covered: []string{"c/f.txt"}}},
+		},
+		{
+			name: "重复内容",
+			change: func(t *testing.T, root string) {
+				writeFile(t, filepath.Join(root, "dup.txt"), "top")
+			},
+			added:  []string{"dup.txt"},
+			copies: []Rename{{From: "top.txt", To: "dup.txt"}},
+		},
+	}
+
+	names := newNameMapper(SymlinkSkip, NormalizeOff, IllegalOff, CaseOff)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "sync")
+			writeFile(t, filepath.Join(root, "a", "f.txt"), "hello")
+			writeFile(t, filepath.Join(root, "top.txt"), "top")
+			journalPath := filepath.Join(t.TempDir(), journalFileName)
+
+			// 第一次扫描，记录全部条目并保存
+			journal, err := LoadJournal(journalPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := journal.Scan(root, names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range first.Current {
+				journal.Record(entry)
+			}
+			if err := journal.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.change(t, root)
+
+			journal, err = LoadJournal(journalPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs, err := journal.Scan(root, names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check := func(field string, got, want any) {
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+			check("Added", cs.Added, tt.added)
+			check("Modified", cs.Modified, tt.modified)
+			check("Removed", cs.Removed, tt.removed)
+			check("Renames", cs.Renames, tt.renames)
+			check("Copies", cs.Copies, tt.copies)
+			if len(tt.added)+len(tt.modified)+len(tt.removed) == 0 && !cs.Unchanged("") {
+				t.Error("本地没有变化时根目录应为未变化")
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 保证修改后的修改时间与索引中的不同
+	mtime := time.Now().Add(time.Duration(len(content)) * time.Second)
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func remove(t *testing.T, p string) {
+	t.Helper()
+	if err := os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func rename(t *testing.T, from, to string) {
+	t.Helper()
+	if err := os.Rename(from, to); err != nil {
+		t.Fatal(err)
+	}
+}
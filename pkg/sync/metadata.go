@@ -0,0 +1,437 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/util"
+)
+
+// 文件元数据的保存方式
+const (
+	MetadataAuto    = "auto"    // 优先使用自定义属性，服务器不支持时改用元数据文件
+	MetadataProps   = "props"   // 通过 PROPPATCH 保存在每个文件的自定义属性中
+	MetadataSidecar = "sidecar" // 保存在服务器根目录的元数据文件中
+)
+
+const (
+	metadataName      = ".sws-meta.json" // 服务器根目录下的元数据文件
+	metadataProp      = "meta"           // 保存元数据的自定义属性名
+	metadataCacheName = "metadata.json"  // 本地记录上次写入服务器的元数据
+)
+
+// FileMeta 文件或目录的POSIX元数据
+type FileMeta struct {
+	Mode   uint32            `json:"mode"` // 权限位，包括 setuid、setgid 和 sticky 位；恢复时只应用 0777 部分
+	UID    *int              `json:"uid,omitempty"`
+	GID    *int              `json:"gid,omitempty"`
+	Xattrs map[string][]byte `json:"xattrs,omitempty"`
+}
+
+// metadataCache 本地记录的上次写入服务器的元数据，用于只更新变化的条目
+type metadataCache struct {
+	Store   string               `json:"store"`
+	Entries map[string]*FileMeta `json:"entries"`
+}
+
+// posixMode 将 os.FileMode 转换为POSIX权限位
+func posixMode(mode os.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
+
+// matchXattr 判断扩展属性名是否匹配配置的模式
+func (s *SyncManager) matchXattr(name string) bool {
+	for _, pattern := range s.config.MetadataXattrs {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readFileMeta 读取本地文件的元数据
+func (s *SyncManager) readFileMeta(localPath string, info os.FileInfo) (*FileMeta, error) {
+	meta := &FileMeta{Mode: posixMode(info.Mode())}
+	if uid, gid, ok := util.FileOwner(info); ok {
+		meta.UID, meta.GID = &uid, &gid
+	}
+
+	if len(s.config.MetadataXattrs) == 0 {
+		return meta, nil
+	}
+	names, err := util.ListXattrs(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取扩展属性失败 %s: %w", localPath, err)
+	}
+	for _, name := range names {
+		if !s.matchXattr(name) {
+			continue
+		}
+		value, err := util.GetXattr(localPath, name)
+		if err != nil {
+			return nil, fmt.Errorf("读取扩展属性 %s 失败 %s: %w", name, localPath, err)
+		}
+		if meta.Xattrs == nil {
+			meta.Xattrs = make(map[string][]byte)
+		}
+		meta.Xattrs[name] = value
+	}
+	return meta, nil
+}
+
+// collectMetadata 按符号链接策略遍历本地目录，读取所有文件和目录的元数据
+// 以描述文件保存的符号链接没有元数据
+func (s *SyncManager) collectMetadata() (map[string]*FileMeta, error) {
+	entries := make(map[string]*FileMeta)
+	err := s.walkLocal(func(rel string, entry *localEntry) error {
+		if entry.Link != "" {
+			return nil
+		}
+		localPath := filepath.Join(s.config.LocalDir, filepath.FromSlash(entry.Path))
+		meta, err := s.readFileMeta(localPath, entry.Info)
+		if err != nil {
+			return err
+		}
+		entries[rel] = meta
+		return nil
+	})
+	return entries, err
+}
+
+// sameMeta 比较两份元数据是否一致
+func sameMeta(a, b *FileMeta) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// loadMetadataCache 读取本地记录的上次写入服务器的元数据，文件不存在或损坏时返回空记录
+func (s *SyncManager) loadMetadataCache() *metadataCache {
+	cache := &metadataCache{Entries: make(map[string]*FileMeta)}
+	data, err := os.ReadFile(s.config.StatePath(metadataCacheName))
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, cache); err != nil || cache.Entries == nil {
+		return &metadataCache{Entries: make(map[string]*FileMeta)}
+	}
+	return cache
+}
+
+// saveMetadataCache 保存本地元数据记录
+func (s *SyncManager) saveMetadataCache(cache *metadataCache) {
+	data, err := json.Marshal(cache)
+	if err == nil {
+		p := s.config.StatePath(metadataCacheName)
+		if err = os.MkdirAll(filepath.Dir(p), 0755); err == nil {
+			err = os.WriteFile(p, data, 0600)
+		}
+	}
+	if err != nil {
+		log.Printf("警告: 保存本地元数据记录失败: %v", err)
+	}
+}
+
+// markUploaded 记录本次运行上传过的文件，这些文件在服务器上的自定义属性需要重新设置
+func (s *SyncManager) markUploaded(remotePath string) {
+	if s.config.Metadata {
+		s.uploaded.Store(relKey(remotePath), true)
+	}
+}
+
+// saveMetadata 备份结束后将本地文件的元数据写入服务器
+func (s *SyncManager) saveMetadata() error {
+	entries, err := s.collectMetadata()
+	if err != nil {
+		return fmt.Errorf("读取本地元数据失败: %w", err)
+	}
+
+	cache := s.loadMetadataCache()
+	store := s.config.MetadataStore
+	if store == MetadataAuto && cache.Store == MetadataSidecar {
+		store = MetadataSidecar // 之前已确认服务器不支持自定义属性
+	}
+
+	if store != MetadataSidecar {
+		err := s.saveMetadataProps(entries, cache)
+		if err == nil {
+			if cache.Store != MetadataProps {
+				// 之前使用元数据文件时删除旧文件，避免恢复时读到过期的元数据
+				if err := s.client.RemoveRemote("/" + metadataName); err != nil && !errors.Is(err, client.ErrNotFound) {
+					log.Printf("警告: 删除旧的元数据文件失败: %v", err)
+				}
+			}
+			s.saveMetadataCache(&metadataCache{Store: MetadataProps, Entries: entries})
+			return nil
+		}
+		// 自动模式下，之前未成功使用过自定义属性时，任何失败都视为服务器不支持
+		if store == MetadataProps || (cache.Store == MetadataProps && !errors.Is(err, client.ErrPropsUnsupported)) {
+			return err
+		}
+		log.Printf("服务器无法保存自定义属性 (%v)，元数据改为保存在 /%s 中", err, metadataName)
+		cache = &metadataCache{Entries: make(map[string]*FileMeta)}
+	}
+
+	if err := s.saveMetadataSidecar(entries, cache); err != nil {
+		return err
+	}
+	s.saveMetadataCache(&metadataCache{Store: MetadataSidecar, Entries: entries})
+	return nil
+}
+
+// saveMetadataProps 通过自定义属性保存元数据，只更新变化的条目和本次上传过的文件
+func (s *SyncManager) saveMetadataProps(entries map[string]*FileMeta, cache *metadataCache) error {
+	updated := 0
+	for rel, meta := range entries {
+		_, uploaded := s.uploaded.Load(rel)
+		if cache.Store == MetadataProps && !uploaded && sameMeta(cache.Entries[rel], meta) {
+			continue
+		}
+		value, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+		err = util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+			return s.client.SetProperty("/"+rel, metadataProp, string(value))
+		})
+		if err != nil {
+			return fmt.Errorf("保存元数据失败 %s: %w", rel, err)
+		}
+		updated++
+	}
+	if updated > 0 {
+		log.Printf("已更新 %d 个条目的元数据", updated)
+	}
+	return nil
+}
+
+// saveMetadataSidecar 将所有条目的元数据保存到服务器根目录的元数据文件中，内容未变化时跳过
+func (s *SyncManager) saveMetadataSidecar(entries map[string]*FileMeta, cache *metadataCache) error {
+	if cache.Store == MetadataSidecar && len(cache.Entries) == len(entries) {
+		changed := false
+		for rel, meta := range entries {
+			if !sameMeta(cache.Entries[rel], meta) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return nil
+		}
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	err = util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+		_, err := s.client.UploadStream("/"+metadataName, bytes.NewReader(data), int64(len(data)), s.runStart)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("保存元数据文件失败: %w", err)
+	}
+	log.Printf("已保存 %d 个条目的元数据到 /%s", len(entries), metadataName)
+	return nil
+}
+
+// loadMetadata 读取服务器上保存的元数据，roots 为空时读取全部条目，否则只读取这些路径及其子路径
+func (s *SyncManager) loadMetadata(roots []string) (map[string]*FileMeta, error) {
+	if s.config.MetadataStore != MetadataProps {
+		entries, err := s.loadMetadataSidecar()
+		if err == nil || s.config.MetadataStore == MetadataSidecar || !errors.Is(err, client.ErrNotFound) {
+			return entries, err
+		}
+	}
+	return s.loadMetadataProps(roots)
+}
+
+// loadMetadataSidecar 读取服务器根目录的元数据文件
+func (s *SyncManager) loadMetadataSidecar() (map[string]*FileMeta, error) {
+	reader, err := s.client.ReadStream("/" + metadataName)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var entries map[string]*FileMeta
+	if err := json.NewDecoder(reader).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("解析元数据文件失败: %w", err)
+	}
+	return entries, nil
+}
+
+// loadMetadataProps 逐个目录读取自定义属性中保存的元数据
+func (s *SyncManager) loadMetadataProps(roots []string) (map[string]*FileMeta, error) {
+	if len(roots) == 0 {
+		roots = []string{"/"}
+	}
+
+	// 需要读取属性的目录：每个目录的 Depth: 1 PROPFIND 同时返回目录本身和直接子条目的属性
+	dirs := make(map[string]bool)
+	for _, root := range roots {
+		root = "/" + relKey(root)
+		info, err := s.client.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir {
+			dirs[path.Dir(root)] = true
+			continue
+		}
+		dirs[root] = true
+		entries, err := s.walkRemote(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir && !isReserved(entry.Path) {
+				dirs["/"+relKey(entry.Path)] = true
+			}
+		}
+	}
+
+	result := make(map[string]*FileMeta)
+	for dir := range dirs {
+		props, err := s.client.Properties(dir, metadataProp)
+		if err != nil {
+			return nil, err
+		}
+		for p, value := range props {
+			var meta FileMeta
+			if err := json.Unmarshal([]byte(value), &meta); err != nil {
+				log.Printf("警告: 跳过无法解析的元数据 %s: %v", p, err)
+				continue
+			}
+			result[relKey(p)] = &meta
+		}
+	}
+	return result, nil
+}
+
+// applyMetadata 将服务器上保存的元数据应用到本地文件
+// roots 为空时应用到全部条目，否则只应用到这些路径及其子路径；单个条目失败只给出警告
+func (s *SyncManager) applyMetadata(roots []string) {
+	entries, err := s.loadMetadata(roots)
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			log.Printf("服务器上没有保存元数据，跳过恢复权限和属性")
+		} else {
+			log.Printf("警告: 读取服务器上的元数据失败: %v", err)
+		}
+		return
+	}
+
+	applied := 0
+	for rel, meta := range entries {
+		if rel == "" || !underRoots(rel, roots) {
+			continue
+		}
+		localPath, err := s.localPath(rel)
+		if err != nil {
+			s.rejectPath(rel, err)
+			continue
+		}
+		changed, err := s.applyFileMeta(localPath, meta)
+		if err != nil {
+			log.Printf("警告: 恢复元数据失败 %s: %v", rel, err)
+			continue
+		}
+		if changed {
+			applied++
+		}
+	}
+	if applied > 0 {
+		log.Printf("已恢复 %d 个条目的权限和属性", applied)
+	}
+}
+
+// applyFileMeta 将元数据应用到单个本地文件，返回是否做了修改
+// 只有以 root 运行时才恢复属主和属组；不会删除本地已有的其他扩展属性
+func (s *SyncManager) applyFileMeta(localPath string, meta *FileMeta) (bool, error) {
+	info, err := os.Lstat(localPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if s.config.Symlinks != SymlinkFollow {
+			return false, nil
+		}
+		if info, err = os.Stat(localPath); err != nil {
+			return false, nil
+		}
+	}
+
+	changed := false
+	if os.Geteuid() == 0 && meta.UID != nil && meta.GID != nil {
+		if uid, gid, ok := util.FileOwner(info); ok && (uid != *meta.UID || gid != *meta.GID) {
+			if err := os.Chown(localPath, *meta.UID, *meta.GID); err != nil {
+				return changed, err
+			}
+			changed = true
+		}
+	}
+
+	// 修改属主和扩展属性之后最后设置权限
+	for name, value := range meta.Xattrs {
+		if !s.matchXattr(name) {
+			continue
+		}
+		if current, err := util.GetXattr(localPath, name); err == nil && bytes.Equal(current, value) {
+			continue
+		}
+		if err := util.SetXattr(localPath, name, value); err != nil {
+			return changed, fmt.Errorf("设置扩展属性 %s 失败: %w", name, err)
+		}
+		changed = true
+	}
+
+	// 服务器上的数据不可信，不恢复 setuid、setgid 和 sticky 位，避免借此生成特权程序
+	perm := os.FileMode(meta.Mode) & os.ModePerm
+	if changed || info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != perm {
+		if err := os.Chmod(localPath, perm); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// underRoots 判断相对路径是否位于任一给定路径之下（包括路径本身），roots 为空时始终为 true
+func underRoots(rel string, roots []string) bool {
+	if len(roots) == 0 {
+		return true
+	}
+	for _, root := range roots {
+		root = relKey(root)
+		if root == "" || rel == root || strings.HasPrefix(rel, root+"/") {
+			return true
+		}
+	}
+	return false
+}
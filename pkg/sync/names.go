@@ -0,0 +1,386 @@
+package sync
+
+import (
+	"log"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"SyncUsingWebDav/pkg/client"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 文件名的 Unicode 规范化方式
+const (
+	NormalizeOff = "off" // 保持原样
+	NormalizeNFC = "nfc" // 组合形式，Windows 和 Linux 上的常见形式
+	NormalizeNFD = "nfd" // 分解形式，macOS HFS+ 使用的形式
+)
+
+// 无法在 Windows 上使用的文件名的处理方式
+const (
+	IllegalOff    = "off"    // 不检查
+	IllegalReport = "report" // 给出警告，在 Windows 上跳过这些文件
+	IllegalEscape = "escape" // 在本地替换为带前缀的相似字符，上传时还原
+)
+
+// 大小写冲突的检查方式
+const (
+	CaseAuto  = "auto"  // 恢复到 Windows 或 macOS 时检查
+	CaseCheck = "check" // 始终检查
+	CaseOff   = "off"   // 不检查
+)
+
+// windowsReserved Windows 保留的设备名，不区分大小写，带扩展名时同样不可用
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// escapeChars Windows 文件名中不允许的字符及其替换字符（全角形式）
+var escapeChars = map[rune]rune{
+	'<': '＜', '>': '＞', ':': '：', '"': '＂', '\\': '＼', '|': '｜', '?': '？', '*': '＊',
+}
+
+// 文件名末尾的点和空格在 Windows 上会被去掉，转义为以下字符
+const (
+	escapedDot   = '．'
+	escapedSpace = '␠'
+)
+
+// escapeMark 转义字符的前缀：转义得到的替换字符前都带有该前缀，
+// 文件名中原有的全角字符等不带前缀，保持原样，因此转义可以无歧义地还原
+const escapeMark = '‛'
+
+// illegalReason 返回文件名不能在 Windows 上使用的原因，可以使用时返回空字符串
+func illegalReason(name string) string {
+	for _, r := range name {
+		if r < 0x20 {
+			return "包含控制字符"
+		}
+		if _, ok := escapeChars[r]; ok {
+			return "包含字符 " + string(r)
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return "以点或空格结尾"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(strings.TrimRight(base, " "))] {
+		return "是 Windows 保留的设备名"
+	}
+	return ""
+}
+
+// escapedRune 判断 runes[i] 是否为 escapeName 使用的替换字符，是时返回被替换的原字符
+// 替换点和空格的字符只在名称末尾有效
+func escapedRune(runes []rune, i int) (rune, bool) {
+	r := runes[i]
+	switch {
+	case r >= 0x2400 && r < 0x2420:
+		return r - 0x2400, true
+	case r == escapedDot && i == len(runes)-1:
+		return '.', true
+	case r == escapedSpace && i == len(runes)-1:
+		return ' ', true
+	}
+	for orig, escaped := range escapeChars {
+		if r == escaped {
+			return orig, true
+		}
+	}
+	return 0, false
+}
+
+// needsEscape 判断 runes[i] 是否为 Windows 不允许的字符，是时返回带前缀的替换字符
+func needsEscape(runes []rune, i int) (string, bool) {
+	r := runes[i]
+	switch {
+	case r < 0x20:
+		return string([]rune{escapeMark, 0x2400 + r}), true // 控制字符的图形表示 ␀-␟
+	case escapeChars[r] != 0:
+		return string([]rune{escapeMark, escapeChars[r]}), true
+	case i == len(runes)-1 && r == '.':
+		return string([]rune{escapeMark, escapedDot}), true
+	case i == len(runes)-1 && r == ' ':
+		return string([]rune{escapeMark, escapedSpace}), true
+	}
+	return "", false
+}
+
+// escapeName 将文件名中 Windows 不允许的字符替换为带 escapeMark 前缀的相似字符，保留设备名无法转义
+// 原有的 escapeMark 后面紧跟会被 unescapeName 识别的内容时重复一次，其余字符保持原样
+func escapeName(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if escaped, ok := needsEscape(runes, i); ok {
+			b.WriteString(escaped)
+			continue
+		}
+		if r == escapeMark && i+1 < len(runes) {
+			next := runes[i+1]
+			_, escapable := needsEscape(runes, i+1)
+			_, replacement := escapedRune(runes, i+1)
+			if next == escapeMark || escapable || replacement {
+				b.WriteRune(escapeMark)
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// unescapeName 还原 escapeName 转义的字符，只有带 escapeMark 前缀的替换字符会被还原
+// 不是 escapeName 生成的名称（如本地文件名中原有的全角字符）保持原样
+func unescapeName(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == escapeMark && i+1 < len(runes) {
+			if runes[i+1] == escapeMark {
+				i++
+			} else if orig, ok := escapedRune(runes, i+1); ok {
+				r = orig
+				i++
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// nameMapper 在本地名称和服务器上的名称之间转换，并记录无法表示或相互冲突的名称
+// 列表、上传、下载和删除计算都通过它转换名称，保证两侧的路径一一对应
+type nameMapper struct {
+	symlinks   string // 符号链接处理方式，link 时服务器上的描述文件带有 linkExt 扩展名
+	normalize  string // Unicode 规范化方式
+	illegal    string // 无法在 Windows 上使用的文件名的处理方式
+	caseLocal  bool   // 写入本地时是否检查只有大小写不同的名称
+	caseRemote bool   // 上传时是否检查只有大小写不同的名称
+
+	mu       sync.Mutex
+	reported map[string]bool
+}
+
+// newNameMapper 创建名称转换器
+// caseCollisions 为 auto 时只在恢复到大小写不敏感的系统（Windows、macOS）时检查，check 时上传也检查
+func newNameMapper(symlinks, normalize, illegal, caseCollisions string) *nameMapper {
+	return &nameMapper{
+		symlinks:   symlinks,
+		normalize:  normalize,
+		illegal:    illegal,
+		caseLocal:  caseCollisions == CaseCheck || (caseCollisions == CaseAuto && (runtime.GOOS == "windows" || runtime.GOOS == "darwin")),
+		caseRemote: caseCollisions == CaseCheck,
+		reported:   make(map[string]bool),
+	}
+}
+
+// signature 返回影响名称转换结果的设置，设置变化后本地索引中的名称不能直接使用
+// 转义时附加前缀字符，转义方式改变后同样视为设置变化
+func (m *nameMapper) signature() string {
+	if m.illegal == IllegalEscape {
+		return m.normalize + "," + m.illegal + "," + string(escapeMark)
+	}
+	return m.normalize + "," + m.illegal
+}
+
+// normalizeName 按配置的方式规范化名称
+func (m *nameMapper) normalizeName(name string) string {
+	switch m.normalize {
+	case NormalizeNFC:
+		return norm.NFC.String(name)
+	case NormalizeNFD:
+		return norm.NFD.String(name)
+	}
+	return name
+}
+
+// remoteName 将本地名称转换为服务器上的名称（不包括符号链接描述文件的扩展名）
+func (m *nameMapper) remoteName(local string) string {
+	if m.illegal == IllegalEscape {
+		local = unescapeName(local)
+	}
+	return m.normalizeName(local)
+}
+
+// localName 将服务器上的名称转换为本地名称，以描述文件保存的符号链接去掉 linkExt 扩展名
+func (m *nameMapper) localName(remote string) string {
+	if m.symlinks == SymlinkLink && isLinkDescriptor(remote) {
+		remote = strings.TrimSuffix(remote, linkExt)
+	}
+	name := m.normalizeName(remote)
+	if m.illegal == IllegalEscape {
+		name = escapeName(name)
+	}
+	return name
+}
+
+// localRel 将服务器上的相对路径逐级转换为本地相对路径，只有最后一级可能是符号链接描述文件
+func (m *nameMapper) localRel(remotePath string) string {
+	key := relKey(remotePath)
+	if key == "" {
+		return ""
+	}
+	parts := strings.Split(key, "/")
+	for i, part := range parts[:len(parts)-1] {
+		parts[i] = m.normalizeName(part)
+		if m.illegal == IllegalEscape {
+			parts[i] = escapeName(parts[i])
+		}
+	}
+	parts[len(parts)-1] = m.localName(parts[len(parts)-1])
+	return strings.Join(parts, "/")
+}
+
+// localPath 返回服务器上的路径对应的本地路径，服务器上的根目录对应本地同步目录本身
+// 路径可能写到本地同步目录之外时返回 ErrUnsafePath，所有写入本地的操作都必须先经过这里
+func (s *SyncManager) localPath(remotePath string) (string, error) {
+	rel := s.names.localRel(remotePath)
+	if rel == "" {
+		return s.config.LocalDir, nil
+	}
+	if err := checkLocalRel(rel); err != nil {
+		return "", err
+	}
+	if err := s.checkLocalParents(rel); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.config.LocalDir, filepath.FromSlash(rel)), nil
+}
+
+// report 记录一个名称问题，同一路径只报告一次
+func (m *nameMapper) report(p, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.reported[p] {
+		return
+	}
+	m.reported[p] = true
+	log.Printf("警告: 文件名问题 %s: %s", p, reason)
+}
+
+// issues 返回本次运行报告的名称问题数量
+func (m *nameMapper) issues() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.reported)
+}
+
+// reportNameIssues 在运行结束时汇总文件名问题
+func (s *SyncManager) reportNameIssues() {
+	if n := s.names.issues(); n > 0 {
+		log.Printf("本次运行共有 %d 个文件名无法在所有平台上使用或相互冲突，详见上方的警告", n)
+	}
+}
+
+// checkLocal 检查服务器上的条目能否写入本地，返回 false 表示应跳过该条目
+// illegal_names 为 report 时，无法在 Windows 上使用的名称给出警告，并只在 Windows 上跳过
+func (m *nameMapper) checkLocal(remotePath string) bool {
+	if m.illegal != IllegalReport {
+		return true
+	}
+	reason := illegalReason(m.localName(path.Base(remotePath)))
+	if reason == "" {
+		return true
+	}
+	if runtime.GOOS == "windows" {
+		m.report(remotePath, reason+"，无法在本地创建，已跳过")
+		return false
+	}
+	m.report(remotePath, reason+"，无法恢复到 Windows")
+	return true
+}
+
+// checkRemote 上传前检查服务器上的名称，illegal_names 为 report 时对无法恢复到 Windows 的名称给出警告
+func (m *nameMapper) checkRemote(remotePath string) {
+	if m.illegal != IllegalReport {
+		return
+	}
+	if reason := illegalReason(path.Base(remotePath)); reason != "" {
+		m.report(remotePath, reason+"，无法恢复到 Windows")
+	}
+}
+
+// dropCollisions 去掉同一目录中转换后名称相互冲突的条目，只保留排在最前面的一个
+// names 为各条目的原始名称，targets 为转换到目标一侧后的名称，调用方需先用 collisionOrder 排序；返回保留的条目下标
+func (m *nameMapper) dropCollisions(dir string, names, targets []string, fold bool) []int {
+	seen := make(map[string]string, len(names))
+	keep := make([]int, 0, len(names))
+	for i, target := range targets {
+		key := norm.NFC.String(target)
+		if fold {
+			key = strings.ToLower(key)
+		}
+		if first, ok := seen[key]; ok {
+			m.report(path.Join("/", dir, names[i]), "与 "+first+" 冲突，已跳过")
+			continue
+		}
+		seen[key] = names[i]
+		keep = append(keep, i)
+	}
+	return keep
+}
+
+// collisionOrder 决定名称冲突时保留哪一个条目：转换前后名称一致的优先，其次按名称排序
+func collisionOrder(a, aTarget, b, bTarget string) bool {
+	if aSame, bSame := a == aTarget, b == bTarget; aSame != bSame {
+		return aSame
+	}
+	return a < b
+}
+
+// dropLocalCollisions 去掉本地目录 dir 中上传后名称相互冲突的条目
+func (m *nameMapper) dropLocalCollisions(dir string, entries []*localEntry) []*localEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		return collisionOrder(entries[i].Name, entries[i].Remote, entries[j].Name, entries[j].Remote)
+	})
+	names := make([]string, len(entries))
+	targets := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i], targets[i] = entry.Name, entry.RemoteName()
+	}
+	keep := m.dropCollisions(dir, names, targets, m.caseRemote)
+	if len(keep) == len(entries) {
+		return entries
+	}
+	result := make([]*localEntry, 0, len(keep))
+	for _, i := range keep {
+		result = append(result, entries[i])
+	}
+	return result
+}
+
+// dropRemoteCollisions 去掉服务器上同一目录中写入本地后名称相互冲突的条目，以及不能写入本地的条目
+func (m *nameMapper) dropRemoteCollisions(dir string, files []client.FileInfo) []client.FileInfo {
+	sort.Slice(files, func(i, j int) bool {
+		a, b := path.Base(files[i].Path), path.Base(files[j].Path)
+		return collisionOrder(a, m.localName(a), b, m.localName(b))
+	})
+	var checked []client.FileInfo
+	for _, file := range files {
+		if m.checkLocal(file.Path) {
+			checked = append(checked, file)
+		}
+	}
+	names := make([]string, len(checked))
+	targets := make([]string, len(checked))
+	for i, file := range checked {
+		names[i], targets[i] = path.Base(file.Path), m.localName(path.Base(file.Path))
+	}
+	keep := m.dropCollisions(dir, names, targets, m.caseLocal)
+	if len(keep) == len(checked) {
+		return checked
+	}
+	result := make([]client.FileInfo, 0, len(keep))
+	for _, i := range keep {
+		result = append(result, checked[i])
+	}
+	return result
+}
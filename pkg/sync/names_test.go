@@ -0,0 +1,54 @@
+package sync
+
+import "testing"
+
+func TestEscapeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		escaped string
+	}{
+		{"plain.txt", "plain.txt"},
+		{"a:b", "a‛：b"},
+		{`<>:"\|?*`, "‛＜‛＞‛：‛＂‛＼‛｜‛？‛＊"},
+		{"tab\there", "tab‛␉here"},
+		{"\x00", "‛␀"},
+		{"trailing.", "trailing‛．"},
+		{"trailing ", "trailing‛␠"},
+		{"a.b c", "a.b c"},
+		// 原有的全角字符和符号不带前缀，保持原样
+		{"全角：字符＊", "全角：字符＊"},
+		{"．x", "．x"},
+		{"x．", "x．"},
+		{"␠", "␠"},
+		// 原有的前缀字符后面跟着可以被还原的内容时重复一次
+		{"‛", "‛"},
+		{"a‛b", "a‛b"},
+		{"‛：", "‛‛："},
+		{"‛‛", "‛‛‛"},
+		{"a‛.", "a‛‛‛．"},
+		{"x‛．", "x‛‛．"},
+		{"‛．x", "‛．x"},
+		{"‛:", "‛‛‛："},
+	}
+	for _, tt := range tests {
+		escaped := escapeName(tt.name)
+		if escaped != tt.escaped {
+			t.Errorf("escapeName(%q) = %q, want %q", tt.name, escaped, tt.escaped)
+		}
+		if reason := illegalReason(escaped); reason != "" {
+			t.Errorf("escapeName(%q) = %q 仍然%s", tt.name, escaped, reason)
+		}
+		if got := unescapeName(escaped); got != tt.name {
+			t.Errorf("unescapeName(%q) = %q, want %q", escaped, got, tt.name)
+		}
+	}
+}
+
+// TestUnescapeForeignName 不是 escapeName 生成的名称按原样使用
+func TestUnescapeForeignName(t *testing.T) {
+	for _, name := range []string{"全角：字符", "x．", "a‛", "‛b", "‛．x", "‛"} {
+		if got := unescapeName(name); got != name {
+			t.Errorf("unescapeName(%q) = %q, want %q", name, got, name)
+		}
+	}
+}
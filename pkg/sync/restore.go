@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/trash"
+)
+
+// RestorePaths 只恢复指定的远程文件或目录，其余本地文件保持不变
+// target 不为空时恢复到该目录而不是本地同步目录，此时不更新同步状态，避免影响之后的同步
+// 不会删除本地多余的文件
+func (s *SyncManager) RestorePaths(paths []string, target string) error {
+	if len(paths) == 0 {
+		return errors.New("没有指定要恢复的路径")
+	}
+
+	startTime := time.Now()
+	s.runStart = startTime
+	defer s.reportUnsafePaths()
+
+	if err := s.loadState(); err != nil {
+		return err
+	}
+	if target != "" && filepath.Clean(target) != filepath.Clean(s.config.LocalDir) {
+		cfg := *s.config
+		cfg.LocalDir = target
+		s.config = &cfg
+	} else {
+		defer s.saveState()
+	}
+	if s.config.UseTrash {
+		s.trash = trash.New(s.config.TrashPath(), startTime, s.config.LocalDir)
+	}
+
+	var errs []error
+	for _, p := range paths {
+		remotePath := "/" + relKey(p)
+		if isReserved(remotePath) {
+			errs = append(errs, fmt.Errorf("不能恢复保留目录中的路径: %s", p))
+			continue
+		}
+
+		info, err := s.client.Stat(remotePath)
+		if err != nil {
+			if errors.Is(err, client.ErrNotFound) {
+				err = fmt.Errorf("远程路径不存在: %s", remotePath)
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		log.Printf("恢复 %s 到本地目录(%s)", remotePath, s.config.LocalDir)
+		if info.IsDir {
+			localDirPath, err := s.localPath(remotePath)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", p, err))
+				continue
+			}
+			if err := s.ensureLocalDir(localDirPath, s.names.localRel(remotePath)); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err = s.SyncDirectory(remotePath); err == nil {
+				s.deferDirTime(info)
+			}
+		} else {
+			err = s.SyncFile(info)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if s.aborted() {
+				break
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		s.applyLocalDirTimes()
+	}
+	if s.config.Metadata && len(errs) == 0 {
+		s.applyMetadata(paths)
+	}
+
+	elapsed := time.Since(startTime)
+	if len(errs) > 0 {
+		err := fmt.Errorf("恢复过程中发生%d个错误，第一个错误: %w", len(errs), errs[0])
+		log.Printf("恢复失败: %v, 耗时: %s", err, elapsed)
+		return err
+	}
+
+	log.Printf("恢复完成! 耗时: %s", elapsed)
+	return nil
+}
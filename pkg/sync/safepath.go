@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath 服务器上的路径可能写到本地同步目录之外
+var ErrUnsafePath = errors.New("不安全的远程路径")
+
+// checkLocalRel 检查由服务器上的路径转换得到的本地相对路径
+// 拒绝含有空字符、. 或 .. 的路径，以及绝对路径、带盘符的路径等当前系统上不能限制在同步目录内的路径
+func checkLocalRel(rel string) error {
+	if strings.Contains(rel, "\x00") {
+		return fmt.Errorf("%w: 含有空字符", ErrUnsafePath)
+	}
+	if filepath.Separator != '/' && strings.ContainsRune(rel, filepath.Separator) {
+		return fmt.Errorf("%w: 名称中含有路径分隔符 %c", ErrUnsafePath, filepath.Separator)
+	}
+	for _, part := range strings.Split(rel, "/") {
+		if part == "." || part == ".." {
+			return fmt.Errorf("%w: 含有 %s", ErrUnsafePath, part)
+		}
+	}
+	if !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return fmt.Errorf("%w: 不是同步目录内的相对路径", ErrUnsafePath)
+	}
+	return nil
+}
+
+// checkLocalParents 检查本地路径在同步目录内的各级父目录
+// 不跟随符号链接时，父目录不能是符号链接，避免通过（可能是从服务器恢复的）符号链接写到同步目录之外；
+// 跟随符号链接时，作为父目录的符号链接解析后必须仍在同步目录内
+// 不存在或不是目录的父目录之后的部分会在写入时重新创建，不再检查
+func (s *SyncManager) checkLocalParents(rel string) error {
+	parts := strings.Split(rel, "/")
+	current := s.config.LocalDir
+	for _, part := range parts[:len(parts)-1] {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil || (!info.IsDir() && info.Mode()&os.ModeSymlink == 0) {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if s.config.Symlinks != SymlinkFollow {
+			return fmt.Errorf("%w: 父目录 %s 是符号链接", ErrUnsafePath, current)
+		}
+		if err := s.checkInsideLocalDir(current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkInsideLocalDir 检查解析所有符号链接后的路径仍在同步目录内
+func (s *SyncManager) checkInsideLocalDir(p string) error {
+	root, err := filepath.EvalSymlinks(s.config.LocalDir)
+	if err != nil {
+		return fmt.Errorf("%w: 无法解析同步目录: %v", ErrUnsafePath, err)
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return fmt.Errorf("%w: 无法解析符号链接 %s: %v", ErrUnsafePath, p, err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return fmt.Errorf("%w: 父目录 %s 指向同步目录之外的 %s", ErrUnsafePath, p, resolved)
+	}
+	return nil
+}
+
+// rejectPath 报告一个因不安全而跳过的远程路径
+func (s *SyncManager) rejectPath(remotePath string, err error) {
+	s.unsafePaths.Add(1)
+	log.Printf("警告: 跳过 %s: %v", remotePath, err)
+}
+
+// reportUnsafePaths 在运行结束时汇总被跳过的不安全路径
+func (s *SyncManager) reportUnsafePaths() {
+	if n := s.unsafePaths.Load(); n > 0 {
+		log.Printf("本次运行共跳过 %d 个可能写到本地同步目录之外的远程路径，详见上方的警告", n)
+	}
+}
@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"SyncUsingWebDav/pkg/config"
+)
+
+func TestCheckLocalRel(t *testing.T) {
+	tests := []struct {
+		rel string
+		ok  bool
+	}{
+		{"a.txt", true},
+		{"a/b/c.txt", true},
+		{"..a/b..", true},
+		{"../a", false},
+		{"a/../../b", false},
+		{"a/./b", false},
+		{"/etc/passwd", false},
+		{"a\x00b", false},
+	}
+	for _, tt := range tests {
+		err := checkLocalRel(tt.rel)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkLocalRel(%q) = %v, want ok=%v", tt.rel, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("checkLocalRel(%q) = %v, want ErrUnsafePath", tt.rel, err)
+		}
+	}
+}
+
+// TestLocalPathSymlinkParents 父目录是指向同步目录之外的符号链接时，所有模式下都拒绝写入
+func TestLocalPathSymlinkParents(t *testing.T) {
+	root := t.TempDir()
+	local := filepath.Join(root, "sync")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(local, "real"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(local, "out")); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(local, "real"), filepath.Join(local, "in")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../..", filepath.Join(local, "real", "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remote string
+		follow bool // 跟随符号链接时是否允许
+	}{
+		{"/plain/a.txt", true},
+		{"/real/a.txt", true},
+		{"/out/a.txt", false},
+		{"/out/sub/a.txt", false},
+		{"/real/up/outside/a.txt", false},
+		{"/in/a.txt", true},
+		{"/in/up/a.txt", false},
+	}
+	for _, mode := range []string{SymlinkSkip, SymlinkLink, SymlinkFollow} {
+		cfg := &config.Config{LocalDir: local, Symlinks: mode, MaxConcurrent: 1}
+		s := NewSyncManager(nil, cfg)
+		for _, tt := range tests {
+			want := tt.follow
+			if mode != SymlinkFollow && tt.remote != "/plain/a.txt" && tt.remote != "/real/a.txt" {
+				want = false // 不跟随时父目录不能是任何符号链接
+			}
+			_, err := s.localPath(tt.remote)
+			if (err == nil) != want {
+				t.Errorf("symlinks=%s: localPath(%q) = %v, want ok=%v", mode, tt.remote, err, want)
+			}
+			if err != nil && !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("symlinks=%s: localPath(%q) = %v, want ErrUnsafePath", mode, tt.remote, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,739 @@
+package sync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/trash"
+	"SyncUsingWebDav/pkg/util"
+)
+
+// 快照存储在服务器上的布局：
+//
+//	/.snapshots/chunks/<摘要前两位>/<SHA-256摘要>   按内容寻址的数据块，跨文件、跨快照去重
+//	/.snapshots/manifests/<快照编号>.json.gz        每次快照的文件清单
+//
+// 文件按固定大小切分为数据块，相同内容的数据块只保存一份
+const (
+	snapshotsDir      = ".snapshots"
+	snapshotChunkSize = 4 * 1024 * 1024
+	snapshotIDLayout  = "20060102T150405.000Z" // 精确到毫秒，避免同一秒内创建的快照使用相同的编号
+	manifestExt       = ".json.gz"
+	manifestVersion   = 1
+)
+
+// Manifest 一次快照的文件清单
+type Manifest struct {
+	Version int              `json:"version"`
+	ID      string           `json:"id"`
+	Time    time.Time        `json:"time"`
+	Source  string           `json:"source"`
+	Files   []*ManifestEntry `json:"files"`
+}
+
+// ManifestEntry 快照中的一个文件或目录
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	IsDir   bool      `json:"is_dir,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Chunks  []string  `json:"chunks,omitempty"`
+	Link    string    `json:"link,omitempty"` // 以描述文件保存的符号链接的目标
+}
+
+// SnapshotInfo 快照概要
+type SnapshotInfo struct {
+	ID    string    `json:"id"`
+	Time  time.Time `json:"time"`
+	Files int       `json:"files"`
+	Size  int64     `json:"size"`
+}
+
+// SnapshotDiff 两个快照之间的差异
+type SnapshotDiff struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// chunkStore 本次运行已知存在于服务器上的数据块
+type chunkStore struct {
+	mu    sync.Mutex
+	known map[string]bool
+}
+
+// claim 标记数据块即将上传，已存在或其他文件正在上传时返回 false
+func (c *chunkStore) claim(sum string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.known[sum] {
+		return false
+	}
+	c.known[sum] = true
+	return true
+}
+
+// release 数据块上传失败时取消标记
+func (c *chunkStore) release(sum string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.known, sum)
+}
+
+// chunkPath 返回数据块在服务器上的路径，sum 必须是 isChunkID 检查过的名称
+func chunkPath(sum string) string {
+	return "/" + path.Join(snapshotsDir, "chunks", sum[:2], sum)
+}
+
+// isSnapshotID 判断是否为有效的快照编号
+func isSnapshotID(id string) bool {
+	_, err := time.Parse(snapshotIDLayout, id)
+	return err == nil
+}
+
+// isChunkID 判断是否为有效的数据块名称（SHA-256 的十六进制形式）
+// 来自服务器的名称必须先经过检查才能用于 chunkPath
+func isChunkID(sum string) bool {
+	if len(sum) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(sum)
+	return err == nil
+}
+
+// manifestPath 返回快照清单在服务器上的路径
+func manifestPath(id string) string {
+	return "/" + path.Join(snapshotsDir, "manifests", id+manifestExt)
+}
+
+// SnapshotBackup 创建一个新快照：上传本地目录中新的数据块并写入本次的文件清单
+func (s *SyncManager) SnapshotBackup() error {
+	startTime := time.Now()
+	s.runStart = startTime
+	id := startTime.UTC().Format(snapshotIDLayout)
+
+	// 上一个快照中大小和修改时间都未变化的文件直接沿用其数据块，无需重新读取
+	previous := make(map[string]*ManifestEntry)
+	ids, err := s.snapshotIDs()
+	if err != nil {
+		return err
+	}
+	for _, existing := range ids {
+		if existing == id {
+			return fmt.Errorf("快照 %s 已存在", id)
+		}
+	}
+	if len(ids) > 0 {
+		last, err := s.loadManifest(ids[len(ids)-1])
+		if err != nil {
+			return err
+		}
+		for _, entry := range last.Files {
+			previous[entry.Path] = entry
+		}
+	}
+
+	store, err := s.listChunks()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(store))
+	for sum := range store {
+		known[sum] = true
+	}
+	chunks := &chunkStore{known: known}
+
+	manifest := &Manifest{
+		Version: manifestVersion,
+		ID:      id,
+		Time:    startTime,
+		Source:  s.config.LocalDir,
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	var uploaded, reused int
+
+	err = s.walkLocal(func(rel string, local *localEntry) error {
+		info := local.Info
+		entry := &ManifestEntry{Path: rel, IsDir: local.Link == "" && info.IsDir(), ModTime: info.ModTime(), Link: local.Link}
+		manifest.Files = append(manifest.Files, entry)
+		if entry.IsDir || entry.Link != "" || !info.Mode().IsRegular() {
+			return nil
+		}
+		entry.Size = info.Size()
+		p := filepath.Join(s.config.LocalDir, filepath.FromSlash(local.Path))
+
+		if old, ok := previous[rel]; ok && !old.IsDir && old.Size == entry.Size && old.ModTime.Equal(entry.ModTime) {
+			entry.Chunks = old.Chunks
+			return nil
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.semaphore <- struct{}{}
+			defer func() { <-s.semaphore }()
+			if s.aborted() {
+				return
+			}
+
+			n, m, err := s.snapshotFile(p, entry, chunks)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				s.checkFatal(err)
+				errs = append(errs, err)
+				return
+			}
+			uploaded += n
+			reused += m
+		}()
+		return nil
+	})
+	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("读取本地目录失败: %w", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("快照过程中发生%d个错误，未写入快照清单，第一个错误: %w", len(errs), errs[0])
+	}
+
+	if err := s.saveManifest(manifest); err != nil {
+		return err
+	}
+
+	log.Printf("快照 %s 已完成: %d 个条目, 上传 %d 个新数据块, 复用 %d 个数据块, 耗时: %s",
+		id, len(manifest.Files), uploaded, reused, time.Since(startTime))
+	return nil
+}
+
+// snapshotFile 切分单个文件并上传服务器上还没有的数据块，返回上传和复用的数据块数
+func (s *SyncManager) snapshotFile(localPath string, entry *ManifestEntry, chunks *chunkStore) (int, int, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("打开本地文件失败 %s: %w", localPath, err)
+	}
+	defer file.Close()
+
+	var uploaded, reused int
+	var total int64
+	buf := make([]byte, snapshotChunkSize)
+	for {
+		n, err := io.ReadFull(file, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return uploaded, reused, fmt.Errorf("读取本地文件失败 %s: %w", localPath, err)
+		}
+
+		data := buf[:n]
+		total += int64(n)
+		sum := sha256.Sum256(data)
+		hexSum := hex.EncodeToString(sum[:])
+		entry.Chunks = append(entry.Chunks, hexSum)
+
+		if !chunks.claim(hexSum) {
+			reused++
+		} else {
+			err := util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+				_, err := s.client.UploadStream(chunkPath(hexSum), bytes.NewReader(data), int64(len(data)), s.runStart)
+				return err
+			})
+			if err != nil {
+				chunks.release(hexSum)
+				return uploaded, reused, fmt.Errorf("上传数据块失败 %s: %w", entry.Path, err)
+			}
+			uploaded++
+		}
+
+		if n < snapshotChunkSize {
+			break
+		}
+	}
+
+	// 读取期间文件被修改时，清单中记录的大小以实际读取的内容为准
+	entry.Size = total
+	if info, err := file.Stat(); err == nil && !info.ModTime().Equal(entry.ModTime) {
+		log.Printf("警告: 文件在快照过程中被修改: %s", entry.Path)
+	}
+	log.Printf("快照文件: %s (%s, 新数据块 %d/%d)", entry.Path, util.FormatSize(entry.Size), uploaded, len(entry.Chunks))
+	return uploaded, reused, nil
+}
+
+// RestoreSnapshot 将本地目录恢复为指定快照时的状态，id 为空或 latest 时使用最新的快照
+func (s *SyncManager) RestoreSnapshot(id string) error {
+	startTime := time.Now()
+	s.runStart = startTime
+	s.listIssues = s.client.ListIssues()
+	if s.config.UseTrash {
+		s.trash = trash.New(s.config.TrashPath(), startTime, s.config.LocalDir)
+	}
+
+	manifest, err := s.resolveManifest(id)
+	if err != nil {
+		return err
+	}
+	log.Printf("从快照 %s 恢复到本地目录(%s)，共 %d 个条目", manifest.ID, s.config.LocalDir, len(manifest.Files))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	var stopped atomic.Bool // 创建目录失败后，尚未开始的文件不再恢复
+	addErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	for _, entry := range manifest.Files {
+		if entry.Link != "" {
+			if err := s.restoreSnapshotLink(entry); err != nil {
+				addErr(err)
+			}
+			continue
+		}
+		localPath, err := s.localPath(entry.Path)
+		if err != nil {
+			s.rejectPath(entry.Path, err)
+			continue
+		}
+		if entry.IsDir {
+			if err := s.ensureLocalDir(localPath, s.names.localRel(entry.Path)); err != nil {
+				// 不再启动新的文件恢复，等待已启动的完成后返回
+				stopped.Store(true)
+				addErr(err)
+				break
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(entry *ManifestEntry) {
+			defer wg.Done()
+			s.semaphore <- struct{}{}
+			defer func() { <-s.semaphore }()
+			if s.aborted() || stopped.Load() {
+				return
+			}
+			if err := s.restoreSnapshotFile(entry, localPath); err != nil {
+				s.checkFatal(err)
+				addErr(err)
+			}
+		}(entry)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return fmt.Errorf("恢复过程中发生%d个错误，第一个错误: %w", len(errs), errs[0])
+	}
+
+	// 删除快照中不存在的本地文件
+	if s.config.SyncDelete {
+		log.Println("检查并删除本地多余的文件...")
+		localFiles, err := s.buildLocalFileList()
+		if err != nil {
+			return fmt.Errorf("获取本地文件列表失败: %w", err)
+		}
+		var snapshotFiles []string
+		for _, entry := range manifest.Files {
+			snapshotFiles = append(snapshotFiles, entry.Path)
+		}
+
+		filesToDelete := findExtraFiles(localFiles, snapshotFiles)
+		if err := s.checkDeletions(filesToDelete, snapshotFiles, localFiles); err != nil {
+			return err
+		}
+		s.deleteLocalExtras(filesToDelete)
+	}
+
+	// 子条目全部写入和删除后设置目录的修改时间
+	for _, entry := range manifest.Files {
+		if !entry.IsDir {
+			continue
+		}
+		localPath, err := s.localPath(entry.Path)
+		if err != nil {
+			continue
+		}
+		if err := setLocalDirTime(localPath, entry.ModTime); err != nil {
+			log.Printf("警告: 设置目录修改时间失败 %s: %v", entry.Path, err)
+		}
+	}
+
+	s.pruneTrash()
+	log.Printf("已恢复到快照 %s! 耗时: %s", manifest.ID, time.Since(startTime))
+	return nil
+}
+
+// restoreSnapshotFile 从数据块恢复单个文件，本地文件大小和修改时间一致时跳过
+func (s *SyncManager) restoreSnapshotFile(entry *ManifestEntry, localPath string) error {
+	// 不跟随符号链接时，不通过本地的符号链接写入文件
+	if s.config.Symlinks != SymlinkFollow {
+		if info, err := os.Lstat(localPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			log.Printf("跳过本地符号链接: %s", entry.Path)
+			return nil
+		}
+	}
+
+	stat, err := os.Stat(localPath)
+	exists := err == nil
+	if exists && !stat.IsDir() && stat.Size() == entry.Size && sameModTime(stat.ModTime(), entry.ModTime) {
+		return nil
+	}
+
+	if exists && s.trash != nil {
+		if _, err := s.trash.Keep(localPath, entry.Path); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("创建目录 %s 失败: %w", filepath.Dir(localPath), err)
+	}
+
+	log.Printf("恢复文件: %s (%s)", entry.Path, util.FormatSize(entry.Size))
+	return util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+		reader := &chunkReader{client: s.client, chunks: entry.Chunks}
+		defer reader.Close()
+		if err := client.WriteLocalFile(localPath, reader, entry.ModTime); err != nil {
+			return fmt.Errorf("恢复文件失败 %s: %w", entry.Path, err)
+		}
+		return nil
+	})
+}
+
+// restoreSnapshotLink 将快照中的符号链接恢复为本地符号链接
+func (s *SyncManager) restoreSnapshotLink(entry *ManifestEntry) error {
+	rel := s.names.localRel(strings.TrimSuffix(entry.Path, linkExt))
+	localPath, err := s.localPath(strings.TrimSuffix(entry.Path, linkExt))
+	if err != nil {
+		s.rejectPath(entry.Path, err)
+		return nil
+	}
+
+	if current, err := os.Readlink(localPath); err == nil && current == entry.Link {
+		return nil
+	}
+	if stat, err := os.Lstat(localPath); err == nil {
+		if stat.IsDir() {
+			return fmt.Errorf("无法恢复符号链接 %s: 本地已存在同名目录", rel)
+		}
+		if s.trash != nil {
+			if _, err := s.trash.Put(localPath, rel); err != nil {
+				return err
+			}
+		} else if err := os.Remove(localPath); err != nil {
+			return fmt.Errorf("删除本地文件失败 %s: %w", localPath, err)
+		}
+	} else if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("创建目录 %s 失败: %w", filepath.Dir(localPath), err)
+	}
+
+	log.Printf("创建符号链接: %s -> %s", rel, entry.Link)
+	if err := os.Symlink(entry.Link, localPath); err != nil {
+		return fmt.Errorf("创建符号链接失败 %s: %w", localPath, err)
+	}
+	return nil
+}
+
+// chunkReader 依次读取并校验文件的各个数据块
+type chunkReader struct {
+	client  client.Remote
+	chunks  []string
+	current io.ReadCloser
+	sum     string
+	hash    hash.Hash
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			r.sum, r.chunks = r.chunks[0], r.chunks[1:]
+			body, err := r.client.ReadStream(chunkPath(r.sum))
+			if err != nil {
+				return 0, fmt.Errorf("读取数据块 %s 失败: %w", r.sum, err)
+			}
+			r.current = body
+			r.hash = sha256.New()
+		}
+
+		n, err := r.current.Read(p)
+		r.hash.Write(p[:n])
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if hex.EncodeToString(r.hash.Sum(nil)) != r.sum {
+				return n, fmt.Errorf("数据块 %s 校验失败，服务器上的数据可能已损坏", r.sum)
+			}
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+// ListSnapshots 列出服务器上的所有快照，按时间排序
+func (s *SyncManager) ListSnapshots() ([]SnapshotInfo, error) {
+	ids, err := s.snapshotIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []SnapshotInfo
+	for _, id := range ids {
+		manifest, err := s.loadManifest(id)
+		if err != nil {
+			return nil, err
+		}
+		info := SnapshotInfo{ID: id, Time: manifest.Time}
+		for _, entry := range manifest.Files {
+			if !entry.IsDir {
+				info.Files++
+				info.Size += entry.Size
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// DiffSnapshots 比较两个快照，返回从 a 到 b 新增、删除和修改的路径
+func (s *SyncManager) DiffSnapshots(a, b string) (*SnapshotDiff, error) {
+	from, err := s.resolveManifest(a)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.resolveManifest(b)
+	if err != nil {
+		return nil, err
+	}
+
+	old := make(map[string]*ManifestEntry, len(from.Files))
+	for _, entry := range from.Files {
+		old[entry.Path] = entry
+	}
+
+	diff := &SnapshotDiff{}
+	for _, entry := range to.Files {
+		prev, ok := old[entry.Path]
+		delete(old, entry.Path)
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, entry.Path)
+		case prev.IsDir != entry.IsDir || prev.Size != entry.Size || strings.Join(prev.Chunks, ",") != strings.Join(entry.Chunks, ","):
+			diff.Modified = append(diff.Modified, entry.Path)
+		}
+	}
+	for p := range old {
+		diff.Removed = append(diff.Removed, p)
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff, nil
+}
+
+// ForgetSnapshot 删除快照清单，数据块需要再执行 PruneSnapshots 才会被清理
+func (s *SyncManager) ForgetSnapshot(id string) error {
+	if !isSnapshotID(id) {
+		return fmt.Errorf("无效的快照编号: %s", id)
+	}
+	if err := s.client.RemoveRemote(manifestPath(id)); err != nil {
+		return fmt.Errorf("删除快照 %s 失败: %w", id, err)
+	}
+	log.Printf("已删除快照 %s", id)
+	return nil
+}
+
+// PruneSnapshots 删除不再被任何快照引用的数据块，返回删除的数据块数和释放的空间
+// 任何一个快照清单无法读取时都不会删除数据块；请勿与快照备份同时运行
+func (s *SyncManager) PruneSnapshots() (int, int64, error) {
+	ids, err := s.snapshotIDs()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	referenced := make(map[string]bool)
+	for _, id := range ids {
+		manifest, err := s.loadManifest(id)
+		if err != nil {
+			return 0, 0, fmt.Errorf("读取快照清单失败，为避免误删数据块已停止清理: %w", err)
+		}
+		for _, entry := range manifest.Files {
+			for _, sum := range entry.Chunks {
+				referenced[sum] = true
+			}
+		}
+	}
+
+	store, err := s.listChunks()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var removed int
+	var freed int64
+	for sum, size := range store {
+		if referenced[sum] {
+			continue
+		}
+		if err := s.client.RemoveRemote(chunkPath(sum)); err != nil && !errors.Is(err, client.ErrNotFound) {
+			log.Printf("警告: 删除数据块失败 %s: %v", sum, err)
+			continue
+		}
+		removed++
+		freed += size
+	}
+	log.Printf("清理快照数据块: 删除 %d 个 (%s)，保留 %d 个", removed, util.FormatSize(freed), len(store)-removed)
+	return removed, freed, nil
+}
+
+// snapshotIDs 返回服务器上所有快照的编号，按时间排序
+func (s *SyncManager) snapshotIDs() ([]string, error) {
+	entries, err := s.client.ListFiles("/" + path.Join(snapshotsDir, "manifests"))
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("列出快照失败: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		name := path.Base(entry.Path)
+		id := strings.TrimSuffix(name, manifestExt)
+		if entry.IsDir || id == name {
+			continue
+		}
+		if isSnapshotID(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// listChunks 列出服务器上所有数据块及其大小
+func (s *SyncManager) listChunks() (map[string]int64, error) {
+	entries, err := s.walkRemote("/" + path.Join(snapshotsDir, "chunks"))
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return map[string]int64{}, nil
+		}
+		return nil, fmt.Errorf("列出快照数据块失败: %w", err)
+	}
+
+	chunks := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir {
+			continue
+		}
+		sum := path.Base(entry.Path)
+		if !isChunkID(sum) {
+			log.Printf("警告: 跳过数据块目录中的无关文件: %s", entry.Path)
+			continue
+		}
+		chunks[sum] = entry.Size
+	}
+	return chunks, nil
+}
+
+// resolveManifest 读取指定快照的清单，id 为空或 latest 时读取最新的快照
+func (s *SyncManager) resolveManifest(id string) (*Manifest, error) {
+	if id == "" || id == "latest" {
+		ids, err := s.snapshotIDs()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, errors.New("服务器上还没有任何快照")
+		}
+		id = ids[len(ids)-1]
+	}
+	if !isSnapshotID(id) {
+		return nil, fmt.Errorf("无效的快照编号: %s", id)
+	}
+	return s.loadManifest(id)
+}
+
+// loadManifest 从服务器读取快照清单
+func (s *SyncManager) loadManifest(id string) (*Manifest, error) {
+	reader, err := s.client.ReadStream(manifestPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("读取快照 %s 失败: %w", id, err)
+	}
+	defer reader.Close()
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("解析快照 %s 失败: %w", id, err)
+	}
+	var manifest Manifest
+	if err := json.NewDecoder(gz).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("解析快照 %s 失败: %w", id, err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("不支持的快照格式版本: %d", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// saveManifest 将快照清单写入服务器，所有数据块上传完成后才写入，保证清单引用的数据块都存在
+func (s *SyncManager) saveManifest(manifest *Manifest) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(manifest); err != nil {
+		return fmt.Errorf("序列化快照清单失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("序列化快照清单失败: %w", err)
+	}
+
+	// 已存在同名清单时不能覆盖，否则原快照丢失后其数据块会在清理时被删除
+	exists, err := s.client.FileExists(manifestPath(manifest.ID))
+	if err != nil {
+		return fmt.Errorf("保存快照清单失败: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("保存快照清单失败: 快照 %s 已存在", manifest.ID)
+	}
+
+	err = util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+		_, err := s.client.UploadStream(manifestPath(manifest.ID), bytes.NewReader(buf.Bytes()), int64(buf.Len()), manifest.Time)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("保存快照清单失败: %w", err)
+	}
+	return nil
+}
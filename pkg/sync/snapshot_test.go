@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsChunkID(t *testing.T) {
+	valid := strings.Repeat("0123456789abcdef", 4)
+	tests := []struct {
+		sum string
+		ok  bool
+	}{
+		{valid, true},
+		{strings.ToUpper(valid), true},
+		{"", false},
+		{"a", false},
+		{valid[:63], false},
+		{valid + "0", false},
+		{valid[:62] + "zz", false},
+		{valid[:62] + "/x", false},
+		{"../" + valid[3:], false},
+	}
+	for _, tt := range tests {
+		if got := isChunkID(tt.sum); got != tt.ok {
+			t.Errorf("isChunkID(%q) = %v, want %v", tt.sum, got, tt.ok)
+		}
+	}
+}
+
+func TestIsSnapshotID(t *testing.T) {
+	tests := []struct {
+		id string
+		ok bool
+	}{
+		{"20240101T120000.000Z", true},
+		{"20240101T120000.123Z", true},
+		{"20240101T120000Z", false},
+		{"20240101T120000.1234Z", false},
+		{"latest", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isSnapshotID(tt.id); got != tt.ok {
+			t.Errorf("isSnapshotID(%q) = %v, want %v", tt.id, got, tt.ok)
+		}
+	}
+}
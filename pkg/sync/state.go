@@ -0,0 +1,159 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+)
+
+// 同步状态文件名及格式版本
+const (
+	stateFileName = "state.json"
+	stateVersion  = 1
+)
+
+// StateEntry 记录一个文件上次同步时在本地和服务器两侧的状态
+type StateEntry struct {
+	LocalSize     int64     `json:"local_size"`
+	LocalModTime  time.Time `json:"local_mtime"`
+	RemoteSize    int64     `json:"remote_size"`
+	RemoteModTime time.Time `json:"remote_mtime"`
+}
+
+// stateFile 同步状态的磁盘格式
+type stateFile struct {
+	Version int                    `json:"version"`
+	Entries map[string]*StateEntry `json:"entries"`
+}
+
+// SyncState 持久化的同步状态
+// 服务器无法保留上传文件的修改时间时，用它把服务器上的修改时间映射回本地修改时间
+type SyncState struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]*StateEntry
+}
+
+// LoadSyncState 从文件加载同步状态，文件不存在时返回空状态
+func LoadSyncState(path string) (*SyncState, error) {
+	st := &SyncState{
+		path:    path,
+		entries: make(map[string]*StateEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, err
+	}
+
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析同步状态失败: %v", err)
+	}
+	if file.Version == stateVersion && file.Entries != nil {
+		st.entries = file.Entries
+	}
+	return st, nil
+}
+
+// Save 将同步状态原子性地写回磁盘
+func (st *SyncState) Save() error {
+	st.mu.Lock()
+	data, err := json.Marshal(&stateFile{Version: stateVersion, Entries: st.entries})
+	st.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化同步状态失败: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(st.path), 0755); err != nil {
+		return fmt.Errorf("创建状态目录失败: %v", err)
+	}
+
+	tmpFile := st.path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("写入同步状态失败: %v", err)
+	}
+	return os.Rename(tmpFile, st.path)
+}
+
+// Get 获取远程路径对应的同步状态
+func (st *SyncState) Get(remotePath string) (*StateEntry, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	entry, ok := st.entries[relKey(remotePath)]
+	return entry, ok
+}
+
+// Set 记录远程路径的同步状态
+func (st *SyncState) Set(remotePath string, entry *StateEntry) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.entries[relKey(remotePath)] = entry
+}
+
+// Remove 移除远程路径及其所有子路径的同步状态
+func (st *SyncState) Remove(remotePath string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	key := relKey(remotePath)
+	prefix := key + "/"
+	for k := range st.entries {
+		if k == key || strings.HasPrefix(k, prefix) {
+			delete(st.entries, k)
+		}
+	}
+}
+
+// Rename 将远程路径及其所有子路径的同步状态迁移到新路径
+func (st *SyncState) Rename(oldPath, newPath string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	oldKey, newKey := relKey(oldPath), relKey(newPath)
+	prefix := oldKey + "/"
+	for k, entry := range st.entries {
+		if k == oldKey {
+			delete(st.entries, k)
+			st.entries[newKey] = entry
+		} else if strings.HasPrefix(k, prefix) {
+			delete(st.entries, k)
+			st.entries[newKey+"/"+strings.TrimPrefix(k, prefix)] = entry
+		}
+	}
+}
+
+// LocalModTime 返回远程文件对应的本地修改时间
+// 服务器上的文件与上次同步时一致则返回记录的本地修改时间，否则返回服务器上的修改时间
+func (st *SyncState) LocalModTime(file client.FileInfo) time.Time {
+	entry, ok := st.Get(file.Path)
+	if ok && entry.RemoteSize == file.Size && sameModTime(entry.RemoteModTime, file.LastModified) {
+		return entry.LocalModTime
+	}
+	return file.LastModified
+}
+
+// loadState 加载同步状态
+func (s *SyncManager) loadState() error {
+	state, err := LoadSyncState(s.config.StatePath(stateFileName))
+	if err != nil {
+		return fmt.Errorf("加载同步状态失败: %v", err)
+	}
+	s.state = state
+	return nil
+}
+
+// saveState 保存同步状态
+func (s *SyncManager) saveState() {
+	if err := s.state.Save(); err != nil {
+		log.Printf("警告: 保存同步状态失败: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Difference 本地同步目录与服务器之间的一处差异
+type Difference struct {
+	Path          string // 服务器上的相对路径（以 / 分隔）
+	IsDir         bool
+	LocalSize     int64
+	LocalModTime  time.Time
+	RemoteSize    int64
+	RemoteModTime time.Time
+}
+
+// Comparison 本地同步目录与服务器的比较结果
+type Comparison struct {
+	LocalOnly  []Difference // 只在本地存在的条目
+	RemoteOnly []Difference // 只在服务器上存在的条目
+	Modified   []Difference // 两边都存在但修改时间或类型不同的条目
+	Unchanged  int          // 两边一致的文件数
+	Incomplete bool         // 服务器上的目录列表不完整，比较结果可能遗漏条目
+}
+
+// Compare 比较本地同步目录与服务器上的文件，不修改任何文件和同步状态
+// 判断文件是否修改的规则与同步时相同：比较本地修改时间与服务器上对应的本地修改时间
+func (s *SyncManager) Compare() (*Comparison, error) {
+	defer s.reportNameIssues()
+
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
+	listIssues := s.client.ListIssues()
+
+	local := make(map[string]*localEntry)
+	err := s.walkLocal(func(rel string, entry *localEntry) error {
+		local[relKey(rel)] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取本地文件列表失败: %w", err)
+	}
+
+	entries, err := s.walkRemote("/")
+	if err != nil {
+		return nil, fmt.Errorf("获取WebDAV文件列表失败: %w", err)
+	}
+
+	result := &Comparison{Incomplete: s.client.ListIssues() > listIssues}
+	remote := make(map[string]bool, len(entries))
+	for _, file := range entries {
+		key := relKey(file.Path)
+		if key == "" || isReserved(file.Path) {
+			continue
+		}
+		remote[key] = true
+
+		d := Difference{Path: key, IsDir: file.IsDir, RemoteSize: file.Size, RemoteModTime: file.LastModified}
+		entry, ok := local[key]
+		if !ok {
+			result.RemoteOnly = append(result.RemoteOnly, d)
+			continue
+		}
+		d.LocalSize, d.LocalModTime = entry.Info.Size(), entry.Info.ModTime()
+		localDir := entry.Link == "" && entry.Info.IsDir()
+		switch {
+		case localDir != file.IsDir:
+			// 一侧是目录，另一侧是同名文件
+			result.Modified = append(result.Modified, d)
+		case file.IsDir:
+		case sameModTime(d.LocalModTime, s.state.LocalModTime(file)):
+			result.Unchanged++
+		default:
+			result.Modified = append(result.Modified, d)
+		}
+	}
+
+	for key, entry := range local {
+		if !remote[key] {
+			result.LocalOnly = append(result.LocalOnly, Difference{
+				Path:         key,
+				IsDir:        entry.Link == "" && entry.Info.IsDir(),
+				LocalSize:    entry.Info.Size(),
+				LocalModTime: entry.Info.ModTime(),
+			})
+		}
+	}
+
+	for _, list := range [][]Difference{result.LocalOnly, result.RemoteOnly, result.Modified} {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Path < list[j].Path
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,249 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+)
+
+// 符号链接处理策略
+const (
+	SymlinkSkip   = "skip"   // 跳过符号链接
+	SymlinkFollow = "follow" // 跟随符号链接，按链接目标同步，检测循环
+	SymlinkLink   = "link"   // 以描述文件保存符号链接，恢复时重新创建为符号链接
+)
+
+// linkExt 符号链接描述文件在服务器上的扩展名，文件内容为链接目标
+const linkExt = ".sws-link"
+
+// maxLinkTarget 符号链接描述文件的最大长度，超过时视为无效
+const maxLinkTarget = 4096
+
+// localEntry 按符号链接策略解析后的本地条目
+type localEntry struct {
+	Name   string      // 本地名称
+	Path   string      // 本地相对路径（斜杠风格）
+	Remote string      // 转换后在服务器上的名称（不包括符号链接描述文件的扩展名）
+	Info   os.FileInfo // 跟随链接时为链接目标的信息，其余为条目本身的信息
+	Link   string      // 以描述文件保存时的链接目标，其余为空
+}
+
+// RemoteName 返回条目在服务器上的名称，符号链接描述文件带有 linkExt 扩展名
+func (e *localEntry) RemoteName() string {
+	if e.Link != "" {
+		return e.Remote + linkExt
+	}
+	return e.Remote
+}
+
+// isLinkDescriptor 判断远程路径是否为符号链接描述文件
+func isLinkDescriptor(p string) bool {
+	return strings.HasSuffix(p, linkExt) && path.Base(p) != linkExt
+}
+
+// resolveLocal 按符号链接策略解析 root 下本地目录 rel 中名为 name 的条目
+// 返回 nil 表示该条目应被跳过；列表、上传和删除计算都通过此函数，保证处理方式一致
+func resolveLocal(root, rel, name string, names *nameMapper) (*localEntry, error) {
+	p := filepath.Join(root, filepath.FromSlash(rel), name)
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	childRel := path.Join(relKey(rel), name)
+	remote := names.remoteName(name)
+	if info.Mode()&os.ModeSymlink == 0 {
+		return &localEntry{Name: name, Path: childRel, Remote: remote, Info: info}, nil
+	}
+
+	switch names.symlinks {
+	case SymlinkFollow:
+		target, err := os.Stat(p)
+		if err != nil {
+			log.Printf("警告: 跳过无法访问的符号链接: %s: %v", childRel, err)
+			return nil, nil
+		}
+		if target.IsDir() {
+			if loop, err := isAncestor(root, rel, target); err != nil {
+				return nil, err
+			} else if loop {
+				log.Printf("警告: 跳过形成循环的符号链接: %s", childRel)
+				return nil, nil
+			}
+		}
+		return &localEntry{Name: name, Path: childRel, Remote: remote, Info: target}, nil
+
+	case SymlinkLink:
+		target, err := os.Readlink(p)
+		if err != nil {
+			return nil, fmt.Errorf("读取符号链接失败 %s: %w", p, err)
+		}
+		return &localEntry{Name: name, Path: childRel, Remote: remote, Info: info, Link: target}, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// isAncestor 判断目录是否与 rel 本身或其任一上级目录（直到 root）为同一目录
+func isAncestor(root, rel string, dir os.FileInfo) (bool, error) {
+	rel = relKey(rel)
+	for {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return false, err
+		}
+		if os.SameFile(info, dir) {
+			return true, nil
+		}
+		if rel == "" {
+			return false, nil
+		}
+		rel = relKey(path.Dir(rel))
+	}
+}
+
+// readLocalDir 按符号链接策略读取本地目录 rel 中的条目
+// 转换后在服务器上名称相同的条目只保留按名称排序后的第一个
+func (s *SyncManager) readLocalDir(rel string) ([]*localEntry, error) {
+	dirPath := filepath.Join(s.config.LocalDir, filepath.FromSlash(rel))
+	names, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取本地目录失败 %s: %w", dirPath, err)
+	}
+
+	var entries []*localEntry
+	for _, name := range names {
+		entry, err := resolveLocal(s.config.LocalDir, rel, name.Name(), s.names)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("获取本地文件信息失败: %w", err)
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return s.names.dropLocalCollisions(rel, entries), nil
+}
+
+// walkLocal 按符号链接策略递归遍历本地同步目录
+// fn 收到的 rel 为服务器上的相对路径（斜杠风格），保留目录不会被遍历
+func (s *SyncManager) walkLocal(fn func(rel string, entry *localEntry) error) error {
+	var walk func(dir, remoteDir string) error
+	walk = func(dir, remoteDir string) error {
+		entries, err := s.readLocalDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			rel := path.Join(remoteDir, entry.RemoteName())
+			if isReserved(rel) {
+				continue
+			}
+			if err := fn(rel, entry); err != nil {
+				return err
+			}
+			if entry.Link == "" && entry.Info.IsDir() {
+				if err := walk(path.Join(dir, entry.Name), rel); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk("", "")
+}
+
+// linkHash 计算符号链接描述文件内容的摘要，与普通文件的摘要可以直接比较
+func linkHash(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return hex.EncodeToString(sum[:])
+}
+
+// restoreLink 将服务器上的符号链接描述文件恢复为本地符号链接
+func (s *SyncManager) restoreLink(file client.FileInfo) error {
+	rel := s.names.localRel(file.Path)
+	localPath, err := s.localPath(file.Path)
+	if err != nil {
+		s.rejectPath(file.Path, err)
+		return nil
+	}
+
+	stat, err := os.Lstat(localPath)
+	exists := err == nil
+	if exists && stat.Mode()&os.ModeSymlink != 0 && sameModTime(stat.ModTime(), s.state.LocalModTime(file)) {
+		log.Printf("跳过未修改的符号链接: %s", rel)
+		return nil
+	}
+	if exists && stat.IsDir() {
+		return fmt.Errorf("无法恢复符号链接 %s: 本地已存在同名目录", rel)
+	}
+
+	reader, err := s.client.ReadStream(file.Path)
+	if err != nil {
+		return fmt.Errorf("读取符号链接描述文件失败 %s: %w", file.Path, err)
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxLinkTarget+1))
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("读取符号链接描述文件失败 %s: %w", file.Path, err)
+	}
+	if len(data) == 0 || len(data) > maxLinkTarget {
+		return fmt.Errorf("无效的符号链接描述文件: %s", file.Path)
+	}
+	target := string(data)
+
+	if exists {
+		if current, err := os.Readlink(localPath); err == nil && current == target {
+			s.recordLink(file, localPath)
+			return nil
+		}
+		if s.trash != nil {
+			saved, err := s.trash.Put(localPath, rel)
+			if err != nil {
+				return err
+			}
+			log.Printf("已将被覆盖的本地文件保存到回收站: %s", saved)
+		} else if err := os.Remove(localPath); err != nil {
+			return fmt.Errorf("删除本地文件失败 %s: %w", localPath, err)
+		}
+	} else if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("创建目录 %s 失败: %w", filepath.Dir(localPath), err)
+	}
+
+	log.Printf("创建符号链接: %s -> %s", rel, target)
+	if err := os.Symlink(target, localPath); err != nil {
+		return fmt.Errorf("创建符号链接失败 %s: %w", localPath, err)
+	}
+	s.recordLink(file, localPath)
+	return nil
+}
+
+// recordLink 记录恢复后的符号链接状态，符号链接本身的修改时间无法设置，以创建后的时间为准
+func (s *SyncManager) recordLink(file client.FileInfo, localPath string) {
+	info, err := os.Lstat(localPath)
+	if err != nil {
+		return
+	}
+	s.state.Set(file.Path, &StateEntry{
+		LocalSize:     info.Size(),
+		LocalModTime:  info.ModTime(),
+		RemoteSize:    file.Size,
+		RemoteModTime: file.LastModified,
+	})
+}
+
+// uploadLink 将符号链接以描述文件的形式上传
+func (s *SyncManager) uploadLink(target, remotePath string, modTime time.Time) (client.UploadResult, error) {
+	return s.client.UploadStream(remotePath, strings.NewReader(target), int64(len(target)), modTime)
+}
@@ -1,45 +1,83 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"SyncUsingWebDav/pkg/client"
 	"SyncUsingWebDav/pkg/config"
+	"SyncUsingWebDav/pkg/trash"
 	"SyncUsingWebDav/pkg/util"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // SyncManager 同步管理器，负责协调同步过程
 type SyncManager struct {
-	client    *client.WebDAVClient
+	client    client.Remote
 	config    *config.Config
 	semaphore chan struct{} // 用于控制并发
+
+	names *nameMapper // 本地名称与服务器上名称的转换
+
+	state   *SyncState // 同步状态
+	journal *Journal   // 本地变更索引（仅备份模式且启用时）
+	changes *ChangeSet // 本次备份扫描得到的本地变更
+
+	runStart time.Time    // 本次运行的开始时间
+	trash    *trash.Trash // 本地回收站（仅恢复模式且启用时）
+
+	listIssues  int64        // 运行开始时客户端已记录的列表问题次数
+	unsafePaths atomic.Int64 // 本次运行因不安全而跳过的远程路径数
+
+	uploaded sync.Map // 本次运行上传过的文件（仅启用元数据时）
+
+	dirsMu sync.Mutex
+	dirs   []client.FileInfo // 本次运行处理过的目录，在最后统一设置修改时间
+
+	fatalMu  sync.Mutex
+	fatalErr error // 导致同步中止的致命错误
 }
 
 // NewSyncManager 创建一个新的同步管理器
-func NewSyncManager(client *client.WebDAVClient, cfg *config.Config) *SyncManager {
+func NewSyncManager(client client.Remote, cfg *config.Config) *SyncManager {
 	return &SyncManager{
 		client:    client,
 		config:    cfg,
 		semaphore: make(chan struct{}, cfg.MaxConcurrent),
+		names:     newNameMapper(cfg.Symlinks, cfg.NameNormalization, cfg.IllegalNames, cfg.CaseCollisions),
 	}
 }
 
 // StartSync 开始同步过程
 func (s *SyncManager) StartSync() error {
+	defer s.reportNameIssues()
+	defer s.reportUnsafePaths()
+
 	// 根据同步模式执行不同的同步方向
 	switch s.config.GetSyncMode() {
 	case config.BackupMode:
 		log.Printf("运行备份模式: 从本地目录(%s)同步到WebDAV(%s)...", s.config.LocalDir, s.config.WebdavURL)
 		return s.BackupToWebDAV()
 	case config.RestoreMode:
+		if s.config.Snapshot != "" {
+			log.Printf("运行恢复模式: 从WebDAV(%s)的快照 %s 恢复到本地目录(%s)...", s.config.WebdavURL, s.config.Snapshot, s.config.LocalDir)
+			return s.RestoreSnapshot(s.config.Snapshot)
+		}
 		log.Printf("运行恢复模式: 从WebDAV(%s)同步到本地目录(%s)...", s.config.WebdavURL, s.config.LocalDir)
 		return s.RestoreFromWebDAV()
+	case config.SnapshotMode:
+		log.Printf("运行快照模式: 为本地目录(%s)创建快照到WebDAV(%s)...", s.config.LocalDir, s.config.WebdavURL)
+		return s.SnapshotBackup()
 	default:
 		return fmt.Errorf("未知的同步模式: %s", s.config.Mode)
 	}
@@ -48,11 +86,21 @@ func (s *SyncManager) StartSync() error {
 // RestoreFromWebDAV 从WebDAV恢复到本地（原有的同步功能）
 func (s *SyncManager) RestoreFromWebDAV() error {
 	startTime := time.Now()
+	s.runStart = startTime
+	s.listIssues = s.client.ListIssues()
+	if s.config.UseTrash {
+		s.trash = trash.New(s.config.TrashPath(), startTime, s.config.LocalDir)
+	}
+
+	if err := s.loadState(); err != nil {
+		return err
+	}
+	defer s.saveState()
 
 	// 获取远程文件列表
 	remoteFiles, err := s.buildRemoteFileList("/")
 	if err != nil {
-		return fmt.Errorf("获取WebDAV文件列表失败: %v", err)
+		return fmt.Errorf("获取WebDAV文件列表失败: %w", err)
 	}
 
 	// 同步WebDAV到本地
@@ -65,27 +113,30 @@ func (s *SyncManager) RestoreFromWebDAV() error {
 		// 获取本地文件列表
 		localFiles, err := s.buildLocalFileList()
 		if err != nil {
-			return fmt.Errorf("获取本地文件列表失败: %v", err)
+			return fmt.Errorf("获取本地文件列表失败: %w", err)
 		}
 
 		// 找出本地多余的文件
 		filesToDelete := findExtraFiles(localFiles, remoteFiles)
+		if err := s.checkDeletions(filesToDelete, remoteFiles, localFiles); err != nil {
+			return err
+		}
 
-		// 按照路径长度降序排序，确保先删除子文件和子目录
-		sort.Slice(filesToDelete, func(i, j int) bool {
-			return len(filesToDelete[i]) > len(filesToDelete[j])
-		})
+		s.deleteLocalExtras(filesToDelete)
+	}
 
-		// 删除多余的文件
-		for _, filePath := range filesToDelete {
-			localPath := filepath.Join(s.config.LocalDir, filePath)
-			log.Printf("删除本地多余文件: %s", localPath)
-			if err := os.RemoveAll(localPath); err != nil {
-				log.Printf("警告: 删除文件失败: %s: %v", localPath, err)
-			}
-		}
+	// 子条目全部写入和删除后设置目录的修改时间
+	if err == nil {
+		s.applyLocalDirTimes()
+	}
+
+	// 恢复文件权限、属主和扩展属性
+	if s.config.Metadata && err == nil {
+		s.applyMetadata(nil)
 	}
 
+	s.pruneTrash()
+
 	elapsed := time.Since(startTime)
 	if err != nil {
 		log.Printf("恢复失败: %v, 耗时: %s", err, elapsed)
@@ -99,15 +150,41 @@ func (s *SyncManager) RestoreFromWebDAV() error {
 // BackupToWebDAV 备份本地文件到WebDAV
 func (s *SyncManager) BackupToWebDAV() error {
 	startTime := time.Now()
+	s.runStart = startTime
+	s.listIssues = s.client.ListIssues()
 
-	// 获取本地文件列表
-	localFiles, err := s.buildLocalFileList()
-	if err != nil {
-		return fmt.Errorf("获取本地文件列表失败: %v", err)
+	if err := s.loadState(); err != nil {
+		return err
+	}
+	defer s.saveState()
+
+	var localFiles []string
+	var err error
+	if s.config.UseJournal {
+		// 使用本地变更索引计算变更集合
+		if err := s.loadJournal(); err != nil {
+			return err
+		}
+		defer s.saveJournal()
+
+		localFiles = s.changes.Paths()
+		log.Printf("本地变更: 新增 %d, 修改 %d, 删除 %d, 重命名 %d",
+			len(s.changes.Added), len(s.changes.Modified), len(s.changes.Removed), len(s.changes.Renames))
+
+		s.replayRenames()
+	} else {
+		// 获取本地文件列表
+		localFiles, err = s.buildLocalFileList()
+		if err != nil {
+			return fmt.Errorf("获取本地文件列表失败: %w", err)
+		}
 	}
 
 	// 同步本地文件到WebDAV
-	err = s.syncLocalToWebDAV("/")
+	err = s.syncLocalToWebDAV("", "/")
+	if err == nil && s.journal != nil {
+		s.journal.Record(s.changes.Current[""])
+	}
 
 	// 如果配置了删除操作，删除远程多余的文件
 	if s.config.SyncDelete && err == nil {
@@ -116,11 +193,14 @@ func (s *SyncManager) BackupToWebDAV() error {
 		// 获取远程文件列表
 		remoteFiles, err := s.buildRemoteFileList("/")
 		if err != nil {
-			return fmt.Errorf("获取WebDAV文件列表失败: %v", err)
+			return fmt.Errorf("获取WebDAV文件列表失败: %w", err)
 		}
 
 		// 找出远程多余的文件
 		filesToDelete := findExtraFiles(remoteFiles, localFiles)
+		if err := s.checkDeletions(filesToDelete, localFiles, remoteFiles); err != nil {
+			return err
+		}
 
 		// 按照路径长度降序排序，确保先删除子文件和子目录
 		sort.Slice(filesToDelete, func(i, j int) bool {
@@ -130,15 +210,34 @@ func (s *SyncManager) BackupToWebDAV() error {
 		// 删除多余的文件
 		for _, filePath := range filesToDelete {
 			log.Printf("删除WebDAV多余文件: %s", filePath)
-			if err := s.client.RemoveRemote(filePath); err != nil {
+			if err := s.removeRemoteVersioned(filePath); err != nil {
 				log.Printf("警告: 删除文件失败: %s: %v", filePath, err)
+				continue
 			}
+			s.state.Remove(filePath)
+		}
+	}
+
+	// 子条目全部上传和删除后设置远程目录的修改时间
+	if err == nil {
+		s.applyRemoteDirTimes()
+	}
+
+	// 按保留规则清理历史版本
+	if s.config.Versioning && err == nil {
+		s.pruneVersions()
+	}
+
+	// 保存文件权限、属主和扩展属性，失败时只给出警告
+	if s.config.Metadata && err == nil {
+		if err := s.saveMetadata(); err != nil {
+			log.Printf("警告: 保存文件元数据失败: %v", err)
 		}
 	}
 
 	elapsed := time.Since(startTime)
 	if err != nil {
-		log.Printf("备份失败: %v, 耗时: %s", err)
+		log.Printf("备份失败: %v, 耗时: %s", err, elapsed)
 		return err
 	}
 
@@ -150,14 +249,20 @@ func (s *SyncManager) BackupToWebDAV() error {
 func (s *SyncManager) SyncDirectory(remotePath string) error {
 	remoteFiles, err := s.client.ListFiles(remotePath)
 	if err != nil {
-		return fmt.Errorf("列出远程文件失败: %v", err)
+		return fmt.Errorf("列出远程文件失败: %w", err)
 	}
 
+	// 去掉写入本地后名称相互冲突或无法写入本地的条目
+	remoteFiles = s.names.dropRemoteCollisions(relKey(remotePath), remoteFiles)
+
 	// 对文件进行排序处理，先分类再排序
 	var directories []client.FileInfo
 	var regularFiles []client.FileInfo
 
 	for _, file := range remoteFiles {
+		if isReserved(file.Path) {
+			continue
+		}
 		if file.IsDir {
 			directories = append(directories, file)
 		} else {
@@ -184,24 +289,43 @@ func (s *SyncManager) SyncDirectory(remotePath string) error {
 		go func(file client.FileInfo) {
 			defer wg.Done()
 
-			// 获取信号量，控制并发
-			s.semaphore <- struct{}{}
-			defer func() { <-s.semaphore }()
-
 			if file.IsDir {
-				// 处理目录
-				localDirPath := filepath.Join(s.config.LocalDir, file.Path)
-				if err := os.MkdirAll(localDirPath, 0755); err != nil {
-					errorsCh <- fmt.Errorf("创建本地目录 %s 失败: %v", localDirPath, err)
+				// 处理目录，不跟随符号链接时不进入本地的符号链接目录
+				ok, err := s.withSlot(func() (bool, error) {
+					localDirPath, err := s.localPath(file.Path)
+					if err != nil {
+						s.rejectPath(file.Path, err)
+						return false, nil
+					}
+					if s.config.Symlinks != SymlinkFollow {
+						if info, err := os.Lstat(localDirPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+							log.Printf("跳过本地符号链接: %s", file.Path)
+							return false, nil
+						}
+					}
+					return true, s.ensureLocalDir(localDirPath, s.names.localRel(file.Path))
+				})
+				if err != nil {
+					errorsCh <- err
+					return
+				}
+				if !ok {
 					return
 				}
 
+				// 递归处理子目录时不占用信号量
 				if err := s.SyncDirectory(file.Path); err != nil {
 					errorsCh <- err
+					return
 				}
+				s.deferDirTime(file)
 			} else {
 				// 处理文件
-				if err := s.SyncFile(file); err != nil {
+				_, err := s.withSlot(func() (bool, error) {
+					return true, s.SyncFile(file)
+				})
+				if err != nil {
+					s.checkFatal(err)
 					errorsCh <- err
 				}
 			}
@@ -218,27 +342,45 @@ func (s *SyncManager) SyncDirectory(remotePath string) error {
 	}
 
 	if len(syncErrors) > 0 {
-		return fmt.Errorf("同步过程中发生%d个错误，第一个错误: %v", len(syncErrors), syncErrors[0])
+		if err := s.fatal(); err != nil {
+			return fmt.Errorf("同步已中止: %w", err)
+		}
+		return fmt.Errorf("同步过程中发生%d个错误，第一个错误: %w", len(syncErrors), syncErrors[0])
 	}
 
 	return nil
 }
 
-// syncLocalToWebDAV 同步本地目录到WebDAV
-func (s *SyncManager) syncLocalToWebDAV(relativePath string) error {
-	localPath := filepath.Join(s.config.LocalDir, relativePath)
+// withSlot 占用一个信号量执行操作，控制同时访问服务器的数量
+// 已发生致命错误时不执行操作，返回 false；操作自身返回是否继续处理该条目
+// 信号量只在访问服务器和本地文件时占用，递归处理子目录前必须释放，否则嵌套层数达到并发数时会死锁
+func (s *SyncManager) withSlot(operation func() (bool, error)) (bool, error) {
+	s.semaphore <- struct{}{}
+	defer func() { <-s.semaphore }()
+
+	if s.aborted() {
+		return false, nil
+	}
+	return operation()
+}
 
-	entries, err := os.ReadDir(localPath)
+// syncLocalToWebDAV 同步本地目录到WebDAV，relativePath 为本地相对路径，remoteDir 为对应的远程目录
+func (s *SyncManager) syncLocalToWebDAV(relativePath, remoteDir string) error {
+	entries, err := s.readLocalDir(relKey(relativePath))
 	if err != nil {
-		return fmt.Errorf("读取本地目录失败 %s: %v", localPath, err)
+		return err
 	}
 
-	// 将文件条目分为目录和普通文件
-	var directories []os.DirEntry
-	var regularFiles []os.DirEntry
+	// 将文件条目分为目录和普通文件，符号链接已按配置的策略处理
+	var directories []*localEntry
+	var regularFiles []*localEntry
 
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if isReserved(path.Join(remoteDir, entry.RemoteName())) {
+			log.Printf("警告: 跳过与保留目录同名的本地条目: %s", filepath.Join(relativePath, entry.Name))
+			continue
+		}
+		if entry.Link == "" && entry.Info.IsDir() {
 			directories = append(directories, entry)
 		} else {
 			regularFiles = append(regularFiles, entry)
@@ -247,11 +389,11 @@ func (s *SyncManager) syncLocalToWebDAV(relativePath string) error {
 
 	// 排序，确保有序处理
 	sort.Slice(directories, func(i, j int) bool {
-		return directories[i].Name() < directories[j].Name()
+		return directories[i].Name < directories[j].Name
 	})
 
 	sort.Slice(regularFiles, func(i, j int) bool {
-		return regularFiles[i].Name() < regularFiles[j].Name()
+		return regularFiles[i].Name < regularFiles[j].Name
 	})
 
 	// 合并排序后的列表，先处理目录
@@ -261,46 +403,47 @@ func (s *SyncManager) syncLocalToWebDAV(relativePath string) error {
 	errorsCh := make(chan error, len(sortedEntries))
 
 	for _, entry := range sortedEntries {
-		entryName := entry.Name()
-		entryRelPath := filepath.Join(relativePath, entryName)
-		remotePath := entryRelPath
-		if remotePath == "." {
-			remotePath = "/"
-		}
-		// 处理 Windows 路径分隔符
-		remotePath = filepath.ToSlash(remotePath)
+		entryRelPath := filepath.Join(relativePath, entry.Name)
+		remotePath := path.Join(remoteDir, entry.RemoteName())
+		s.names.checkRemote(remotePath)
 
 		wg.Add(1)
-		go func(entry os.DirEntry, entryRelPath, remotePath string) {
+		go func(entry *localEntry, entryRelPath, remotePath string) {
 			defer wg.Done()
 
-			// 获取信号量，控制并发
-			s.semaphore <- struct{}{}
-			defer func() { <-s.semaphore }()
+			key := relKey(remotePath)
+			if s.changes != nil && s.changes.Unchanged(key) {
+				// 自上次备份以来未变化，无需访问服务器
+				return
+			}
 
-			if entry.IsDir() {
-				// 处理目录
-				exists, err := s.client.FileExists(remotePath)
+			if entry.Link == "" && entry.Info.IsDir() {
+				// 处理目录，包括空目录
+				ok, err := s.withSlot(func() (bool, error) {
+					return true, s.ensureRemoteDir(remotePath)
+				})
 				if err != nil {
-					errorsCh <- fmt.Errorf("检查远程目录失败 %s: %v", remotePath, err)
+					errorsCh <- err
 					return
 				}
-
-				if !exists {
-					log.Printf("创建远程目录: %s", remotePath)
-					if err := s.client.MakeDir(remotePath); err != nil {
-						errorsCh <- fmt.Errorf("创建远程目录失败 %s: %v", remotePath, err)
-						return
-					}
+				if !ok {
+					return
 				}
 
-				// 递归处理子目录
-				if err := s.syncLocalToWebDAV(entryRelPath); err != nil {
+				// 递归处理子目录时不占用信号量
+				if err := s.syncLocalToWebDAV(entryRelPath, remotePath); err != nil {
 					errorsCh <- err
+					return
 				}
+				s.deferDirTime(client.FileInfo{Path: remotePath, IsDir: true, LastModified: entry.Info.ModTime()})
+				s.recordJournal(key)
 			} else {
 				// 处理文件
-				if err := s.syncLocalFileToWebDAV(entryRelPath, remotePath); err != nil {
+				_, err := s.withSlot(func() (bool, error) {
+					return true, s.syncLocalFileToWebDAV(entryRelPath, remotePath, entry)
+				})
+				if err != nil {
+					s.checkFatal(err)
 					errorsCh <- err
 				}
 			}
@@ -317,46 +460,48 @@ func (s *SyncManager) syncLocalToWebDAV(relativePath string) error {
 	}
 
 	if len(syncErrors) > 0 {
-		return fmt.Errorf("同步过程中发生%d个错误，第一个错误: %v", len(syncErrors), syncErrors[0])
+		if err := s.fatal(); err != nil {
+			return fmt.Errorf("同步已中止: %w", err)
+		}
+		return fmt.Errorf("同步过程中发生%d个错误，第一个错误: %w", len(syncErrors), syncErrors[0])
 	}
 
 	return nil
 }
 
-// syncLocalFileToWebDAV 同步单个本地文件到WebDAV
-func (s *SyncManager) syncLocalFileToWebDAV(relPath, remotePath string) error {
+// syncLocalFileToWebDAV 同步单个本地文件到WebDAV，以描述文件保存的符号链接上传链接目标
+func (s *SyncManager) syncLocalFileToWebDAV(relPath, remotePath string, entry *localEntry) error {
 	localPath := filepath.Join(s.config.LocalDir, relPath)
-
-	// 获取本地文件信息
-	localInfo, err := os.Stat(localPath)
-	if err != nil {
-		return fmt.Errorf("获取本地文件信息失败 %s: %v", localPath, err)
-	}
+	localInfo := entry.Info
 
 	// 检查远程文件是否存在
 	needsUpload := true
 	exists, err := s.client.FileExists(remotePath)
 	if err != nil {
-		return fmt.Errorf("检查远程文件失败 %s: %v", remotePath, err)
+		return fmt.Errorf("检查远程文件失败 %s: %w", remotePath, err)
 	}
 
 	if exists {
 		// 获取远程文件信息
 		remoteFiles, err := s.client.ListFiles(filepath.Dir(remotePath))
 		if err != nil {
-			return fmt.Errorf("获取远程目录信息失败 %s: %v", filepath.Dir(remotePath), err)
+			return fmt.Errorf("获取远程目录信息失败 %s: %w", filepath.Dir(remotePath), err)
 		}
 
 		// 查找匹配的远程文件
 		for _, remoteFile := range remoteFiles {
 			if filepath.Base(remoteFile.Path) == filepath.Base(remotePath) {
-				// 比较修改时间
-				localModTime := localInfo.ModTime()
-				remoteModTime := remoteFile.LastModified
-
-				// 允许 1 秒的时间差
-				if localModTime.Add(time.Second).After(remoteModTime) &&
-					localModTime.Add(-time.Second).Before(remoteModTime) {
+				if remoteFile.IsDir {
+					// 本地目录已被同名文件替换
+					if err := s.replaceRemoteDir(remotePath); err != nil {
+						return err
+					}
+					exists = false
+					break
+				}
+				// 比较修改时间，服务器未保留修改时间时使用同步状态中记录的本地修改时间
+				remoteFile.Path = remotePath
+				if sameModTime(localInfo.ModTime(), s.state.LocalModTime(remoteFile)) {
 					log.Printf("跳过未修改的文件: %s", remotePath)
 					needsUpload = false
 				}
@@ -366,11 +511,25 @@ func (s *SyncManager) syncLocalFileToWebDAV(relPath, remotePath string) error {
 	}
 
 	if needsUpload {
-		log.Printf("上传文件: %s (大小: %s)", remotePath, formatSize(localInfo.Size()))
+		// 覆盖前保存旧文件的历史版本
+		if exists && s.config.Versioning {
+			if err := s.saveVersion(remotePath); err != nil {
+				return err
+			}
+		}
+
+		log.Printf("上传文件: %s (大小: %s)", remotePath, util.FormatSize(localInfo.Size()))
 
 		// 使用重试机制上传文件
-		err := util.Retry(s.config.MaxRetries, s.config.RetryDelay, func() error {
-			return s.client.UploadFile(localPath, remotePath, localInfo.ModTime())
+		var result client.UploadResult
+		err := util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+			var err error
+			if entry.Link != "" {
+				result, err = s.uploadLink(entry.Link, remotePath, localInfo.ModTime())
+			} else {
+				result, err = s.client.UploadFile(localPath, remotePath, localInfo.ModTime())
+			}
+			return err
 		})
 
 		if err != nil {
@@ -378,43 +537,78 @@ func (s *SyncManager) syncLocalFileToWebDAV(relPath, remotePath string) error {
 			return err
 		}
 
-		log.Printf("完成上传: %s (%s)", remotePath, formatSize(localInfo.Size()))
-		return nil
+		s.recordUpload(remotePath, localInfo, result)
+		log.Printf("完成上传: %s (%s)", remotePath, util.FormatSize(localInfo.Size()))
 	}
 
+	s.recordJournal(relKey(remotePath))
 	return nil
 }
 
 // SyncFile 同步单个文件
 func (s *SyncManager) SyncFile(file client.FileInfo) error {
-	localPath := filepath.Join(s.config.LocalDir, file.Path)
+	if isLinkDescriptor(file.Path) {
+		if s.config.Symlinks == SymlinkLink {
+			return s.restoreLink(file)
+		}
+		log.Printf("跳过符号链接描述文件: %s (symlinks = %s)", file.Path, s.config.Symlinks)
+		return nil
+	}
+	localPath, err := s.localPath(file.Path)
+	if err != nil {
+		s.rejectPath(file.Path, err)
+		return nil
+	}
+
+	// 不跟随符号链接时，不通过本地的符号链接写入文件
+	if s.config.Symlinks != SymlinkFollow {
+		if info, err := os.Lstat(localPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			log.Printf("跳过本地符号链接: %s", file.Path)
+			return nil
+		}
+	}
 
 	// 检查本地文件是否存在
 	needsDownload := true
 	stat, err := os.Stat(localPath)
-	if err == nil {
+	exists := err == nil
+	if exists && stat.IsDir() {
+		// 服务器上的目录已被同名文件替换
+		log.Printf("本地同名目录将被替换为文件: %s", file.Path)
+		if err := s.removeLocal(localPath, s.names.localRel(file.Path)); err != nil {
+			return err
+		}
+		exists = false
+	}
+	if exists {
 		// 文件存在，比较修改时间
-		localModTime := stat.ModTime()
-
-		// 允许 1 秒的时间差，因为不同系统可能会有微小差异
-		if localModTime.Add(time.Second).After(file.LastModified) &&
-			localModTime.Add(-time.Second).Before(file.LastModified) {
+		if sameModTime(stat.ModTime(), s.state.LocalModTime(file)) {
 			log.Printf("跳过未修改的文件: %s", file.Path)
 			needsDownload = false
 		}
 	}
 
 	if needsDownload {
-		log.Printf("下载文件: %s (大小: %s)", file.Path, formatSize(file.Size))
+		// 覆盖前将本地旧文件保存到回收站
+		if exists && s.trash != nil {
+			saved, err := s.trash.Keep(localPath, s.names.localRel(file.Path))
+			if err != nil {
+				return err
+			}
+			log.Printf("已将被覆盖的本地文件保存到回收站: %s", saved)
+		}
+
+		log.Printf("下载文件: %s (大小: %s)", file.Path, util.FormatSize(file.Size))
 
 		// 确保父目录存在
 		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
-			return fmt.Errorf("创建目录 %s 失败: %v", filepath.Dir(localPath), err)
+			return fmt.Errorf("创建目录 %s 失败: %w", filepath.Dir(localPath), err)
 		}
 
-		// 使用重试机制下载文件
-		err := util.Retry(s.config.MaxRetries, s.config.RetryDelay, func() error {
-			return s.client.DownloadFile(file.Path, localPath, file.LastModified)
+		// 使用重试机制下载文件，本地修改时间恢复为上传时记录的原始时间
+		localModTime := s.state.LocalModTime(file)
+		err := util.Retry(s.config.MaxRetries, time.Duration(s.config.RetryDelay), func() error {
+			return s.client.DownloadFile(file.Path, localPath, localModTime)
 		})
 
 		if err != nil {
@@ -422,47 +616,147 @@ func (s *SyncManager) SyncFile(file client.FileInfo) error {
 			return err
 		}
 
-		log.Printf("完成下载: %s (%s)", file.Path, formatSize(file.Size))
+		// 文件在服务器上可能以压缩形式保存，本地大小以实际写入的文件为准
+		localSize := file.Size
+		if info, err := os.Stat(localPath); err == nil {
+			localSize = info.Size()
+		}
+		s.state.Set(file.Path, &StateEntry{
+			LocalSize:     localSize,
+			LocalModTime:  localModTime,
+			RemoteSize:    file.Size,
+			RemoteModTime: file.LastModified,
+		})
+
+		log.Printf("完成下载: %s (%s)", file.Path, util.FormatSize(file.Size))
 		return nil
 	}
 
 	return nil
 }
 
-// buildLocalFileList 构建本地文件列表（相对路径）
-func (s *SyncManager) buildLocalFileList() ([]string, error) {
-	var files []string
-	baseDir := s.config.LocalDir
+// recordUpload 记录上传后文件在服务器上的状态，使下一次比较修改时间时结果稳定
+func (s *SyncManager) recordUpload(remotePath string, localInfo os.FileInfo, result client.UploadResult) {
+	s.markUploaded(remotePath)
 
-	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+	remoteModTime := localInfo.ModTime()
+	if !result.ModTimeKept {
+		info, err := s.client.Stat(remotePath)
 		if err != nil {
-			return err
+			log.Printf("警告: 获取上传后的远程文件信息失败: %s: %v", remotePath, err)
+			s.state.Remove(remotePath)
+			return
 		}
+		remoteModTime = info.LastModified
+	}
 
-		// 跳过根目录本身
-		if path == baseDir {
-			return nil
-		}
+	s.state.Set(remotePath, &StateEntry{
+		LocalSize:     localInfo.Size(),
+		LocalModTime:  localInfo.ModTime(),
+		RemoteSize:    result.Size,
+		RemoteModTime: remoteModTime,
+	})
+}
 
-		// 获取相对路径
-		relPath, err := filepath.Rel(baseDir, path)
-		if err != nil {
-			return err
+// removeLocal 删除本地多余的文件或目录，启用回收站时移入回收站
+func (s *SyncManager) removeLocal(localPath, relPath string) error {
+	if s.trash == nil {
+		log.Printf("删除本地多余文件: %s", localPath)
+		return os.RemoveAll(localPath)
+	}
+
+	target, err := s.trash.Put(localPath, relKey(relPath))
+	if err != nil {
+		return err
+	}
+	log.Printf("本地多余文件已移入回收站: %s -> %s", localPath, target)
+	return nil
+}
+
+// deleteLocalExtras 删除本地多余的文件和目录，启用回收站时移入回收站
+func (s *SyncManager) deleteLocalExtras(filesToDelete []string) {
+	// 按照路径长度降序排序，确保先删除子文件和子目录
+	sort.Slice(filesToDelete, func(i, j int) bool {
+		return len(filesToDelete[i]) > len(filesToDelete[j])
+	})
+
+	deleting := make(map[string]bool, len(filesToDelete))
+	for _, filePath := range filesToDelete {
+		deleting[filePath] = true
+	}
+	for _, filePath := range filesToDelete {
+		if s.trash != nil && hasAncestor(filePath, deleting) {
+			continue // 随父目录一起移入回收站
+		}
+		localRel := s.names.localRel(filePath) // 符号链接描述文件对应本地的符号链接
+		localPath := filepath.Join(s.config.LocalDir, localRel)
+		if err := s.removeLocal(localPath, localRel); err != nil {
+			log.Printf("警告: 删除文件失败: %s: %v", localPath, err)
+			continue
 		}
+		if s.state != nil {
+			s.state.Remove(filePath)
+		}
+	}
+}
+
+// pruneTrash 按保留天数清理回收站
+func (s *SyncManager) pruneTrash() {
+	if s.trash == nil {
+		return
+	}
+	if n, err := s.trash.Prune(s.config.TrashKeepDays, s.runStart); err != nil {
+		log.Printf("警告: 清理回收站失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已清理 %d 个过期的回收站目录", n)
+	}
+}
 
-		// 转换为WebDAV风格路径
-		relPath = filepath.ToSlash(relPath)
-		files = append(files, relPath)
+// hasAncestor 判断路径的某个上级目录是否也在集合中
+func hasAncestor(p string, set map[string]bool) bool {
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if set[dir] {
+			return true
+		}
+	}
+	return false
+}
 
+// buildLocalFileList 构建本地文件列表（相对路径）
+// 路径与服务器上的路径一致，符号链接按配置的策略处理，保留目录不在列表中
+func (s *SyncManager) buildLocalFileList() ([]string, error) {
+	var files []string
+	err := s.walkLocal(func(rel string, entry *localEntry) error {
+		files = append(files, rel)
 		return nil
 	})
-
 	return files, err
 }
 
 // buildRemoteFileList 递归构建远程文件列表
+// 路径统一为不带前导斜杠的相对路径，与本地文件列表保持一致；保留目录不在列表中
 func (s *SyncManager) buildRemoteFileList(remotePath string) ([]string, error) {
+	entries, err := s.walkRemote(remotePath)
+	if err != nil {
+		return nil, err
+	}
+
 	var files []string
+	for _, entry := range entries {
+		if isReserved(entry.Path) {
+			continue
+		}
+		files = append(files, strings.TrimPrefix(entry.Path, "/"))
+	}
+	return files, nil
+}
+
+// walkRemote 递归列出远程目录下的所有条目
+func (s *SyncManager) walkRemote(remotePath string) ([]client.FileInfo, error) {
+	// 服务器支持无限深度 PROPFIND 时一次请求取得整个目录树
+	if caps := s.client.Capabilities(); caps != nil && caps.DepthInfinity {
+		return s.client.ListTree(remotePath)
+	}
 
 	// 获取当前目录下的文件
 	entries, err := s.client.ListFiles(remotePath)
@@ -470,18 +764,13 @@ func (s *SyncManager) buildRemoteFileList(remotePath string) ([]string, error) {
 		return nil, err
 	}
 
-	// 将当前路径添加到列表中（除了根路径）
-	if remotePath != "/" && remotePath != "" {
-		files = append(files, remotePath)
-	}
-
-	// 处理所有文件
+	var files []client.FileInfo
 	for _, entry := range entries {
-		files = append(files, entry.Path)
+		files = append(files, entry)
 
-		// 如果是目录，递归处理
-		if entry.IsDir {
-			subFiles, err := s.buildRemoteFileList(entry.Path)
+		// 如果是目录，递归处理；从保留目录之外列出时不进入保留目录
+		if entry.IsDir && (isReserved(remotePath) || !isReserved(entry.Path)) {
+			subFiles, err := s.walkRemote(entry.Path)
 			if err != nil {
 				return nil, err
 			}
@@ -493,17 +782,18 @@ func (s *SyncManager) buildRemoteFileList(remotePath string) ([]string, error) {
 }
 
 // findExtraFiles 找出在source中存在但在target中不存在的文件
+// 路径按 Unicode NFC 形式比较，只有规范化形式不同的同名文件不会被当作多余文件删除
 func findExtraFiles(source, target []string) []string {
 	// 创建target的查找映射
 	targetMap := make(map[string]bool)
 	for _, file := range target {
-		targetMap[file] = true
+		targetMap[norm.NFC.String(file)] = true
 	}
 
 	// 找出多余的文件
 	var extras []string
 	for _, file := range source {
-		if !targetMap[file] {
+		if !targetMap[norm.NFC.String(file)] {
 			extras = append(extras, file)
 		}
 	}
@@ -511,16 +801,42 @@ func findExtraFiles(source, target []string) []string {
 	return extras
 }
 
-// formatSize 格式化文件大小
-func formatSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// checkFatal 检查错误是否会导致后续所有操作都失败（认证失败、服务器存储空间不足），是则中止同步
+func (s *SyncManager) checkFatal(err error) {
+	if !errors.Is(err, client.ErrUnauthorized) && !errors.Is(err, client.ErrInsufficientStorage) {
+		return
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+
+	s.fatalMu.Lock()
+	defer s.fatalMu.Unlock()
+	if s.fatalErr == nil {
+		log.Printf("发生致命错误，中止同步: %v", err)
+		s.fatalErr = err
+	}
+}
+
+// fatal 返回导致同步中止的致命错误
+func (s *SyncManager) fatal() error {
+	s.fatalMu.Lock()
+	defer s.fatalMu.Unlock()
+	return s.fatalErr
+}
+
+// aborted 判断同步是否已因致命错误中止
+func (s *SyncManager) aborted() bool {
+	return s.fatal() != nil
+}
+
+// relKey 将同步过程中使用的本地或远程相对路径统一为不带首尾斜杠的斜杠风格路径
+func relKey(p string) string {
+	key := strings.Trim(filepath.ToSlash(p), "/")
+	if key == "." {
+		return ""
 	}
-	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return key
+}
+
+// sameModTime 比较两个修改时间，允许 1 秒的误差，因为不同系统和服务器的时间精度不同
+func sameModTime(a, b time.Time) bool {
+	return a.Add(time.Second).After(b) && a.Add(-time.Second).Before(b)
 }
@@ -0,0 +1,227 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/config"
+)
+
+// 历史版本在服务器上的存放目录及版本文件名使用的时间格式
+// 每个版本保存为 /.versions/<原路径>/<时间戳>，时间戳精确到毫秒
+const (
+	versionsDir    = ".versions"
+	versionStampFS = "20060102T150405.000Z"
+)
+
+// versionEntry 服务器上的一个历史版本
+type versionEntry struct {
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// isReserved 判断相对路径是否位于程序保留的目录中或为程序保留的文件，这些路径不参与同步和删除
+// 本地同步目录下的状态目录 .sws 同样保留，避免状态文件和回收站被上传或当作多余文件删除
+func isReserved(p string) bool {
+	key := relKey(p)
+	for _, dir := range []string{versionsDir, snapshotsDir, metadataName, config.StateDirName} {
+		if key == dir || strings.HasPrefix(key, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// newVersionPath 返回远程文件的一个尚未使用的历史版本路径
+// 时间戳为当前时间，同一文件在同一毫秒内已有版本时依次顺延 1 毫秒
+func (s *SyncManager) newVersionPath(remotePath string) (string, error) {
+	stamp := time.Now().UTC()
+	for {
+		target := "/" + path.Join(versionsDir, relKey(remotePath), stamp.Format(versionStampFS))
+		exists, err := s.client.FileExists(target)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return target, nil
+		}
+		stamp = stamp.Add(time.Millisecond)
+	}
+}
+
+// saveVersion 在覆盖远程文件前，将旧文件复制到历史版本目录
+// 使用复制而不是移动，新文件上传失败时服务器上仍保留原文件
+func (s *SyncManager) saveVersion(remotePath string) error {
+	return s.storeVersion(remotePath, s.client.Copy)
+}
+
+// archiveVersion 在删除远程文件时，将文件移动到历史版本目录
+func (s *SyncManager) archiveVersion(remotePath string) error {
+	return s.storeVersion(remotePath, s.client.Move)
+}
+
+// storeVersion saveVersion 和 archiveVersion 的公共实现，不覆盖已有的版本
+func (s *SyncManager) storeVersion(remotePath string, transfer func(oldPath, newPath string, overwrite bool) error) error {
+	target, err := s.newVersionPath(remotePath)
+	if err != nil {
+		return fmt.Errorf("保存历史版本失败 %s: %w", remotePath, err)
+	}
+	log.Printf("保存历史版本: %s -> %s", remotePath, target)
+	if err := transfer(remotePath, target, false); err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("保存历史版本失败 %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// removeRemoteVersioned 删除远程多余的条目，启用版本保留时文件会移动到历史版本目录
+func (s *SyncManager) removeRemoteVersioned(remotePath string) error {
+	if !s.config.Versioning {
+		return s.client.RemoveRemote(remotePath)
+	}
+
+	info, err := s.client.Stat(remotePath)
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir {
+		return s.archiveVersion(remotePath)
+	}
+	// 目录中的文件应已先于目录被逐个移走，只删除空目录；
+	// 还有条目时说明其中有文件未能保存历史版本，此时删除目录会连同这些文件一起删除
+	entries, err := s.client.ListFiles(remotePath)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("目录 %s 中还有 %d 个条目未能移入历史版本目录，不删除该目录", remotePath, len(entries))
+	}
+	return s.client.RemoveRemote(remotePath)
+}
+
+// pruneVersions 按保留规则清理历史版本
+// 每个文件保留最近 versions_keep_last 个版本，以及 versions_keep_days 天内每天最后一个版本；
+// 之后若总大小仍超过 versions_max_size，则从最旧的版本开始删除
+func (s *SyncManager) pruneVersions() {
+	entries, err := s.walkRemote("/" + versionsDir)
+	if err != nil {
+		if !errors.Is(err, client.ErrNotFound) {
+			log.Printf("警告: 列出历史版本失败: %v", err)
+		}
+		return
+	}
+
+	groups := make(map[string][]versionEntry)
+	var dirs, others []string
+	for _, entry := range entries {
+		p := "/" + strings.TrimPrefix(entry.Path, "/")
+		if entry.IsDir {
+			dirs = append(dirs, p)
+			continue
+		}
+		t, err := time.Parse(versionStampFS, path.Base(p))
+		if err != nil {
+			others = append(others, p) // 不是本程序创建的版本文件，保留
+			continue
+		}
+		groups[path.Dir(p)] = append(groups[path.Dir(p)], versionEntry{Path: p, Time: t, Size: entry.Size})
+	}
+
+	keepLast := s.config.VersionsKeepLast
+	keepDays := s.config.VersionsKeepDays
+	cutoff := s.runStart.AddDate(0, 0, -keepDays)
+
+	var kept, expired []versionEntry
+	for _, versions := range groups {
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].Time.After(versions[j].Time)
+		})
+
+		days := make(map[string]bool)
+		for i, v := range versions {
+			keep := keepLast <= 0 && keepDays <= 0 // 未设置任何规则时全部保留
+			if keepLast > 0 && i < keepLast {
+				keep = true
+			}
+			if keepDays > 0 && v.Time.After(cutoff) {
+				day := v.Time.Local().Format("2006-01-02")
+				if !days[day] {
+					days[day] = true
+					keep = true
+				}
+			}
+
+			if keep {
+				kept = append(kept, v)
+			} else {
+				expired = append(expired, v)
+			}
+		}
+	}
+
+	// 总大小超出限制时从最旧的版本开始删除
+	if maxSize := int64(s.config.VersionsMaxSize); maxSize > 0 {
+		var total int64
+		for _, v := range kept {
+			total += v.Size
+		}
+		sort.Slice(kept, func(i, j int) bool {
+			return kept[i].Time.Before(kept[j].Time)
+		})
+		for len(kept) > 0 && total > maxSize {
+			total -= kept[0].Size
+			expired = append(expired, kept[0])
+			kept = kept[1:]
+		}
+	}
+
+	if len(expired) == 0 {
+		return
+	}
+
+	log.Printf("清理历史版本: 删除 %d 个，保留 %d 个", len(expired), len(kept))
+	remaining := make(map[string]bool, len(kept)+len(others))
+	for _, p := range others {
+		remaining[p] = true
+	}
+	for _, v := range kept {
+		remaining[v.Path] = true
+	}
+	for _, v := range expired {
+		if err := s.client.RemoveRemote(v.Path); err != nil && !errors.Is(err, client.ErrNotFound) {
+			log.Printf("警告: 删除历史版本失败: %s: %v", v.Path, err)
+			remaining[v.Path] = true
+		}
+	}
+
+	// 删除已经没有任何版本的目录，先删除较深的目录
+	sort.Slice(dirs, func(i, j int) bool {
+		return len(dirs[i]) > len(dirs[j])
+	})
+	for _, dir := range dirs {
+		empty := true
+		for p := range remaining {
+			if strings.HasPrefix(p, dir+"/") {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			if err := s.client.RemoveRemote(dir); err != nil && !errors.Is(err, client.ErrNotFound) {
+				log.Printf("警告: 删除空的历史版本目录失败: %s: %v", dir, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunLayout 回收站中每次运行对应的子目录名格式，同一次运行移入的条目放在同一个子目录中
+const RunLayout = "20060102T150405"
+
+// rootFile 记录该次运行的条目来自哪个本地目录，保存在运行目录中，不作为回收站条目
+const rootFile = ".sws-root"
+
+// Trash 本地回收站
+// 恢复模式删除或覆盖的本地文件按原相对路径保存在 <dir>/<运行时间>/ 下
+type Trash struct {
+	dir  string
+	run  string
+	root string // 本次运行移入的条目所在的本地目录（绝对路径）
+
+	rootOnce sync.Once
+	rootErr  error
+}
+
+// Item 回收站中的一个文件
+type Item struct {
+	Run     string    `json:"run"`
+	Time    time.Time `json:"time"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// New 创建回收站，runStart 决定本次运行移入的条目所在的子目录
+// root 为移入的条目所在的本地目录，记录在运行目录中，找回时恢复到该目录；只读取回收站时可以为空
+func New(dir string, runStart time.Time, root string) *Trash {
+	if root != "" {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+	}
+	return &Trash{
+		dir:  dir,
+		run:  runStart.Format(RunLayout),
+		root: root,
+	}
+}
+
+// markRoot 在本次运行的目录中记录条目所在的本地目录，只在第一次移入条目前写入
+func (t *Trash) markRoot() error {
+	t.rootOnce.Do(func() {
+		runDir := filepath.Join(t.dir, t.run)
+		if err := os.MkdirAll(runDir, 0755); err != nil {
+			t.rootErr = fmt.Errorf("创建回收站目录失败: %w", err)
+			return
+		}
+		if t.root != "" {
+			t.rootErr = os.WriteFile(filepath.Join(runDir, rootFile), []byte(t.root+"\n"), 0600)
+		}
+	})
+	return t.rootErr
+}
+
+// Root 返回某次运行移入的条目所在的本地目录，没有记录时返回空字符串
+func (t *Trash) Root(run string) string {
+	data, err := os.ReadFile(filepath.Join(t.dir, run, rootFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+// isRootFile 判断运行目录中的路径是否为记录本地目录的文件
+func isRootFile(runDir, p string) bool {
+	return p == filepath.Join(runDir, rootFile)
+}
+
+// Dir 返回回收站目录
+func (t *Trash) Dir() string {
+	return t.dir
+}
+
+// target 返回相对路径在本次运行中的回收站路径，已存在时追加序号
+func (t *Trash) target(relPath string) string {
+	base := filepath.Join(t.dir, t.run, filepath.FromSlash(relPath))
+	target := base
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			return target
+		}
+		target = fmt.Sprintf("%s~%d", base, i)
+	}
+}
+
+// Put 将本地文件或目录移入回收站
+// 回收站与同步目录不在同一文件系统时复制后删除原文件
+func (t *Trash) Put(localPath, relPath string) (string, error) {
+	if err := t.markRoot(); err != nil {
+		return "", err
+	}
+	target := t.target(relPath)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("创建回收站目录失败: %w", err)
+	}
+
+	if err := os.Rename(localPath, target); err == nil {
+		return target, nil
+	}
+	if err := copyTree(localPath, target); err != nil {
+		os.RemoveAll(target)
+		return "", fmt.Errorf("移入回收站失败 %s: %w", localPath, err)
+	}
+	if err := os.RemoveAll(localPath); err != nil {
+		return "", fmt.Errorf("删除已移入回收站的文件失败 %s: %w", localPath, err)
+	}
+	return target, nil
+}
+
+// Keep 在本地文件被覆盖前将当前内容保存到回收站，原文件保持不变
+// 优先使用硬链接，新内容写入时原文件被替换，硬链接仍指向旧内容
+func (t *Trash) Keep(localPath, relPath string) (string, error) {
+	if err := t.markRoot(); err != nil {
+		return "", err
+	}
+	target := t.target(relPath)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("创建回收站目录失败: %w", err)
+	}
+
+	if err := os.Link(localPath, target); err == nil {
+		return target, nil
+	}
+	if err := copyFile(localPath, target); err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("保存到回收站失败 %s: %w", localPath, err)
+	}
+	return target, nil
+}
+
+// List 列出回收站中的所有文件，按运行时间和路径排序
+func (t *Trash) List() ([]Item, error) {
+	runs, err := os.ReadDir(t.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取回收站失败: %w", err)
+	}
+
+	var items []Item
+	for _, run := range runs {
+		runTime, err := time.ParseInLocation(RunLayout, run.Name(), time.Local)
+		if !run.IsDir() || err != nil {
+			continue
+		}
+
+		runDir := filepath.Join(t.dir, run.Name())
+		err = filepath.Walk(runDir, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || isRootFile(runDir, p) {
+				return nil
+			}
+			rel, err := filepath.Rel(runDir, p)
+			if err != nil {
+				return err
+			}
+			items = append(items, Item{
+				Run:     run.Name(),
+				Time:    runTime,
+				Path:    filepath.ToSlash(rel),
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("读取回收站失败: %w", err)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Run != items[j].Run {
+			return items[i].Run < items[j].Run
+		}
+		return items[i].Path < items[j].Path
+	})
+	return items, nil
+}
+
+// Restore 将回收站中某次运行的条目恢复到 localDir，调用方应优先使用 Root 返回的该次运行记录的目录
+// relPath 为空时恢复该次运行的全部条目；目标位置已存在的文件不会被覆盖，返回跳过的路径
+func (t *Trash) Restore(run, relPath, localDir string) (restored, skipped []string, err error) {
+	runDir := filepath.Join(t.dir, run)
+	if _, err := time.Parse(RunLayout, run); err != nil {
+		return nil, nil, fmt.Errorf("无效的回收站运行编号: %s", run)
+	}
+
+	src := filepath.Join(runDir, filepath.FromSlash(strings.Trim(relPath, "/")))
+	if src != runDir && !strings.HasPrefix(src, runDir+string(filepath.Separator)) {
+		return nil, nil, fmt.Errorf("无效的路径: %s", relPath)
+	}
+	if _, err := os.Lstat(src); err != nil {
+		return nil, nil, fmt.Errorf("回收站中不存在 %s/%s: %w", run, relPath, err)
+	}
+
+	err = filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || isRootFile(runDir, p) {
+			return nil
+		}
+		rel, err := filepath.Rel(runDir, p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(localDir, rel)
+		if _, err := os.Lstat(dst); err == nil {
+			skipped = append(skipped, filepath.ToSlash(rel))
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(p, dst); err != nil {
+			if err := copyFile(p, dst); err != nil {
+				return err
+			}
+			os.Remove(p)
+		}
+		restored = append(restored, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return restored, skipped, fmt.Errorf("从回收站恢复失败: %w", err)
+	}
+
+	removeEmptyDirs(runDir)
+	// 全部条目都已找回时，记录本地目录的文件也不再需要
+	if entries, err := os.ReadDir(runDir); err == nil && len(entries) == 1 && entries[0].Name() == rootFile {
+		os.Remove(filepath.Join(runDir, rootFile))
+		os.Remove(runDir)
+	}
+	return restored, skipped, nil
+}
+
+// Prune 删除早于 keepDays 天的运行目录，keepDays 不大于 0 时不清理
+func (t *Trash) Prune(keepDays int, now time.Time) (int, error) {
+	if keepDays <= 0 {
+		return 0, nil
+	}
+	runs, err := os.ReadDir(t.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	cutoff := now.AddDate(0, 0, -keepDays)
+	removed := 0
+	var errs []error
+	for _, run := range runs {
+		runTime, err := time.ParseInLocation(RunLayout, run.Name(), time.Local)
+		if !run.IsDir() || err != nil || !runTime.Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(t.dir, run.Name())); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// removeEmptyDirs 自底向上删除空目录（包括 root 本身）
+func removeEmptyDirs(root string) {
+	var dirs []string
+	filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i]) // 非空目录删除失败，忽略
+	}
+}
+
+// copyTree 递归复制文件或目录
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		return copyFile(p, target)
+	})
+}
+
+// copyFile 复制单个文件，保留权限和修改时间
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
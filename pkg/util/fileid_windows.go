@@ -0,0 +1,10 @@
+//go:build windows
+
+package util
+
+import "os"
+
+// FileID 在Windows上无法从 os.FileInfo 获取inode号，始终返回 ok 为 false
+func FileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package util
+
+import "os"
+
+// FileOwner 在Windows上没有POSIX属主，始终返回 ok 为 false
+func FileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandPath 展开路径中的 $VAR、${VAR} 和开头的 ~（当前用户的主目录）
+// 配置文件中的路径和凭据来源中的文件路径都按此规则展开
+func ExpandPath(p string) string {
+	if p == "" {
+		return p
+	}
+	p = os.ExpandEnv(p)
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
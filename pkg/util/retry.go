@@ -1,13 +1,29 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
 )
 
+// retryable 由可以判断自身是否值得重试的错误实现（如 client.Error）
+type retryable interface {
+	Retryable() bool
+}
+
+// retryDelayer 由携带服务器要求的重试等待时间的错误实现
+type retryDelayer interface {
+	RetryDelay() time.Duration
+}
+
 // Retry 执行有重试机制的操作
+// attempts 为最多尝试的次数（包括第一次），必须大于 0
+// 错误声明自身不可重试时立即返回；错误携带等待时间且长于当前退避时间时按其等待
 func Retry(attempts int, sleep time.Duration, operation func() error) error {
+	if attempts < 1 {
+		return fmt.Errorf("无效的尝试次数 %d，至少需要尝试 1 次", attempts)
+	}
 	var err error
 
 	for i := 0; i < attempts; i++ {
@@ -16,12 +32,23 @@ func Retry(attempts int, sleep time.Duration, operation func() error) error {
 			return nil
 		}
 
+		var r retryable
+		if errors.As(err, &r) && !r.Retryable() {
+			return err
+		}
+
 		if i < attempts-1 {
-			log.Printf("操作失败(尝试 %d/%d): %v - 将在 %v 后重试", i+1, attempts, err, sleep)
-			time.Sleep(sleep)
+			wait := sleep
+			var d retryDelayer
+			if errors.As(err, &d) && d.RetryDelay() > wait {
+				wait = d.RetryDelay()
+			}
+
+			log.Printf("操作失败(尝试 %d/%d): %v - 将在 %v 后重试", i+1, attempts, err, wait)
+			time.Sleep(wait)
 			sleep *= 2 // 指数退避策略
 		}
 	}
 
-	return fmt.Errorf("在 %d 次尝试后操作失败: %v", attempts, err)
+	return fmt.Errorf("在 %d 次尝试后操作失败: %w", attempts, err)
 }
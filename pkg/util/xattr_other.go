@@ -0,0 +1,22 @@
+//go:build !linux
+
+package util
+
+import "errors"
+
+var errNoXattr = errors.New("当前平台不支持扩展属性")
+
+// ListXattrs 当前平台不支持扩展属性，始终返回空列表
+func ListXattrs(path string) ([]string, error) {
+	return nil, nil
+}
+
+// GetXattr 当前平台不支持扩展属性
+func GetXattr(path, name string) ([]byte, error) {
+	return nil, errNoXattr
+}
+
+// SetXattr 当前平台不支持扩展属性
+func SetXattr(path, name string, value []byte) error {
+	return errNoXattr
+}
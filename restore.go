@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+
+	"SyncUsingWebDav/pkg/config"
+)
+
+// runRestore 处理 restore 命令
+//
+//	restore                          按配置从服务器恢复整个本地同步目录
+//	restore [-to 目录] <远程路径>...  只恢复指定的远程文件或目录，可以恢复到其他本地目录
+//	restore -to 目录                  将服务器上的所有文件恢复到其他本地目录
+func runRestore(a *app, args []string) error {
+	if len(args) == 0 && a.opts.to == "" {
+		return startSync(a, config.RestoreMode)
+	}
+
+	cfg, err := a.loadConfig(config.RestoreMode)
+	if err != nil {
+		return err
+	}
+	if cfg.Snapshot != "" {
+		return errors.New("-snapshot 不能与 -to 或远程路径同时使用")
+	}
+	m, err := a.manager()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"/"}
+	}
+	return m.RestorePaths(args, a.opts.to)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// runSnapshots 处理 snapshots 命令
+//
+//	snapshots list                 列出服务器上的所有快照
+//	snapshots diff <快照> [快照]    比较两个快照（省略第二个时与最新快照比较）
+//	snapshots forget <快照>         删除快照清单
+//	snapshots prune                删除不再被任何快照引用的数据块
+func runSnapshots(a *app, args []string) error {
+	if len(args) == 0 {
+		return errors.New("用法: snapshots list | snapshots diff <快照> [快照] | snapshots forget <快照> | snapshots prune")
+	}
+	if _, err := a.loadConfig(""); err != nil {
+		return err
+	}
+	m, err := a.manager()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		infos, err := m.ListSnapshots()
+		if err != nil {
+			return err
+		}
+		if len(infos) == 0 {
+			fmt.Println("服务器上还没有任何快照")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "快照\t时间\t文件数\t大小")
+		for _, info := range infos {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", info.ID, info.Time.Local().Format("2006-01-02 15:04:05"), info.Files, info.Size)
+		}
+		return w.Flush()
+
+	case "diff":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New("用法: snapshots diff <快照> [快照]")
+		}
+		to := "latest"
+		if len(args) == 3 {
+			to = args[2]
+		}
+		diff, err := m.DiffSnapshots(args[1], to)
+		if err != nil {
+			return err
+		}
+		for _, p := range diff.Added {
+			fmt.Printf("+ %s\n", p)
+		}
+		for _, p := range diff.Removed {
+			fmt.Printf("- %s\n", p)
+		}
+		for _, p := range diff.Modified {
+			fmt.Printf("M %s\n", p)
+		}
+		fmt.Printf("新增 %d 个，删除 %d 个，修改 %d 个\n", len(diff.Added), len(diff.Removed), len(diff.Modified))
+		return nil
+
+	case "forget":
+		if len(args) != 2 {
+			return errors.New("用法: snapshots forget <快照>")
+		}
+		return m.ForgetSnapshot(args[1])
+
+	case "prune":
+		if len(args) != 1 {
+			return errors.New("用法: snapshots prune")
+		}
+		_, _, err := m.PruneSnapshots()
+		return err
+
+	default:
+		return fmt.Errorf("未知的快照命令: %s (可用: list, diff, forget, prune)", args[0])
+	}
+}
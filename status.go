@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"SyncUsingWebDav/pkg/config"
+	syncPkg "SyncUsingWebDav/pkg/sync"
+)
+
+// compare 加载配置、连接服务器并比较本地同步目录与服务器上的文件
+func compare(a *app, cmd string, args []string) (*config.Config, *syncPkg.Comparison, error) {
+	if err := checkArgs(cmd, args, 0, 0); err != nil {
+		return nil, nil, err
+	}
+	cfg, err := a.loadConfig("")
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := a.manager()
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := m.Compare()
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Incomplete {
+		fmt.Println("警告: 服务器上的目录列表不完整，以下结果可能遗漏部分条目")
+	}
+	return cfg, result, nil
+}
+
+// runStatus 处理 status 命令，统计本地目录与服务器之间的差异，并说明按当前配置同步时将要进行的操作
+func runStatus(a *app, args []string) error {
+	cfg, result, err := compare(a, "status", args)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("配置文件: %s\n", a.opts.configFile)
+	fmt.Printf("本地目录: %s\n", cfg.LocalDir)
+	fmt.Printf("服务器:   %s\n", cfg.WebdavURL)
+	fmt.Printf("仅在本地: %d，仅在服务器上: %d，已修改: %d，一致: %d\n",
+		len(result.LocalOnly), len(result.RemoteOnly), len(result.Modified), result.Unchanged)
+
+	switch cfg.GetSyncMode() {
+	case config.BackupMode:
+		fmt.Printf("备份将上传 %d 个条目", len(result.LocalOnly)+len(result.Modified))
+		if cfg.SyncDelete {
+			fmt.Printf("，删除服务器上 %d 个多余条目", len(result.RemoteOnly))
+		}
+		fmt.Println()
+	case config.RestoreMode:
+		fmt.Printf("恢复将下载 %d 个条目", len(result.RemoteOnly)+len(result.Modified))
+		if cfg.SyncDelete {
+			fmt.Printf("，删除本地 %d 个多余条目", len(result.LocalOnly))
+		}
+		fmt.Println()
+	case config.SnapshotMode:
+		fmt.Println("快照模式每次都为整个本地目录创建新的快照，以上差异只对比服务器上的普通文件")
+	}
+	if len(result.LocalOnly)+len(result.RemoteOnly)+len(result.Modified) > 0 {
+		fmt.Printf("运行 %s diff 查看有差异的条目\n", progName)
+	}
+	return nil
+}
+
+// runDiff 处理 diff 命令，逐行列出有差异的条目
+//
+//	> 路径  只在本地存在
+//	< 路径  只在服务器上存在
+//	M 路径  两边都存在但修改时间或类型不同
+func runDiff(a *app, args []string) error {
+	_, result, err := compare(a, "diff", args)
+	if err != nil {
+		return err
+	}
+	for _, d := range result.LocalOnly {
+		fmt.Printf("> %s\n", diffName(d))
+	}
+	for _, d := range result.RemoteOnly {
+		fmt.Printf("< %s\n", diffName(d))
+	}
+	for _, d := range result.Modified {
+		fmt.Printf("M %s\n", diffName(d))
+	}
+	fmt.Printf("仅在本地 %d 个，仅在服务器上 %d 个，已修改 %d 个\n", len(result.LocalOnly), len(result.RemoteOnly), len(result.Modified))
+	return nil
+}
+
+// diffName 返回差异条目的显示名称，目录以 / 结尾
+func diffName(d syncPkg.Difference) string {
+	if d.IsDir {
+		return d.Path + "/"
+	}
+	return d.Path
+}
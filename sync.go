@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"SyncUsingWebDav/pkg/config"
+)
+
+// runSync 处理 sync 命令，按配置文件中的 mode 同步，不指定命令时运行此命令
+func runSync(a *app, args []string) error {
+	if err := checkArgs("sync", args, 0, 0); err != nil {
+		return err
+	}
+	return startSync(a, "")
+}
+
+// runBackup 处理 backup 命令
+func runBackup(a *app, args []string) error {
+	if err := checkArgs("backup", args, 0, 0); err != nil {
+		return err
+	}
+	return startSync(a, config.BackupMode)
+}
+
+// startSync 按指定的同步模式（为空时按配置文件）运行一次完整的同步
+func startSync(a *app, mode config.SyncMode) error {
+	cfg, err := a.loadConfig(mode)
+	if err != nil {
+		return err
+	}
+
+	// 显示当前模式
+	switch {
+	case cfg.Mode == string(config.BackupMode):
+		fmt.Printf("运行模式: 备份 (本地->WebDAV)\n")
+	case cfg.Mode == string(config.SnapshotMode):
+		fmt.Printf("运行模式: 快照 (本地->WebDAV快照)\n")
+	case cfg.Snapshot != "":
+		fmt.Printf("运行模式: 恢复 (WebDAV快照 %s->本地)\n", cfg.Snapshot)
+	default:
+		fmt.Printf("运行模式: 恢复 (WebDAV->本地)\n")
+	}
+
+	if cfg.SyncDelete {
+		fmt.Printf("启用删除操作: 目标位置中源位置不存在的文件将被删除\n")
+	} else {
+		fmt.Printf("未启用删除操作: 仅同步文件，不会删除目标位置的文件\n")
+	}
+
+	m, err := a.manager()
+	if err != nil {
+		return err
+	}
+
+	// 开始同步过程
+	if err := m.StartSync(); err != nil {
+		return fmt.Errorf("同步失败: %w", err)
+	}
+	return nil
+}
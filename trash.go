@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"SyncUsingWebDav/pkg/trash"
+)
+
+// runTrash 处理 trash 命令
+//
+//	trash list                  列出回收站中的文件
+//	trash restore <运行> [路径]  将某次运行移入回收站的文件（或其中的指定路径）恢复到移入时所在的本地目录
+func runTrash(a *app, args []string) error {
+	if len(args) == 0 {
+		return errors.New("用法: trash list | trash restore <运行> [路径]")
+	}
+	cfg, err := a.loadConfig("")
+	if err != nil {
+		return err
+	}
+	t := trash.New(cfg.TrashPath(), time.Now(), "")
+
+	switch args[0] {
+	case "list":
+		items, err := t.List()
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			fmt.Printf("回收站 %s 为空\n", t.Dir())
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "运行\t路径\t大小\t修改时间")
+		for _, item := range items {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", item.Run, item.Path, item.Size, item.ModTime.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+
+	case "restore":
+		if len(args) < 2 {
+			return errors.New("用法: trash restore <运行> [路径]")
+		}
+		relPath := ""
+		if len(args) > 2 {
+			relPath = args[2]
+		}
+		// 使用 restore -to 时移入回收站的文件来自其他目录，恢复到原来的目录
+		dir := cfg.LocalDir
+		if root := t.Root(args[1]); root != "" {
+			dir = root
+		}
+		fmt.Printf("恢复到本地目录 %s\n", dir)
+		restored, skipped, err := t.Restore(args[1], relPath, dir)
+		for _, p := range restored {
+			fmt.Printf("已恢复: %s\n", p)
+		}
+		for _, p := range skipped {
+			fmt.Printf("已跳过（本地已存在）: %s\n", p)
+		}
+		return err
+
+	default:
+		return fmt.Errorf("未知的回收站命令: %s (可用: list, restore)", args[0])
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"SyncUsingWebDav/pkg/client"
+	"SyncUsingWebDav/pkg/util"
+)
+
+// loadTree 通过逐级列出目录递归读取远程目录树
+// maxDepth 大于 0 时只读取到该层数，level 为当前条目的层数（根为 0）
+func loadTree(remote client.Remote, entry *remoteEntry, level, maxDepth int) error {
+	if !entry.IsDir || (maxDepth > 0 && level >= maxDepth) {
+		return nil
+	}
+	infos, err := remote.ListFiles(entry.Path)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		child := newRemoteEntry(info)
+		if err := loadTree(remote, child, level+1, maxDepth); err != nil {
+			return err
+		}
+		entry.Children = append(entry.Children, child)
+	}
+	sort.Slice(entry.Children, func(i, j int) bool {
+		return entry.Children[i].Name < entry.Children[j].Name
+	})
+	return nil
+}
+
+// statTree 读取位置参数指定的远程目录及其目录树
+func statTree(a *app, cmd string, args []string, maxDepth int) (*remoteEntry, error) {
+	if err := checkArgs(cmd, args, 0, 1); err != nil {
+		return nil, err
+	}
+	remote, err := browse(a)
+	if err != nil {
+		return nil, err
+	}
+	info, err := remote.Stat(remotePathArg(args))
+	if err != nil {
+		return nil, err
+	}
+	root := newRemoteEntry(info)
+	if err := loadTree(remote, root, 0, maxDepth); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// runTree 处理 tree 命令，以树状结构递归列出服务器上的目录
+//
+//	tree [-l] [-depth 层数] [-json] [远程目录]
+func runTree(a *app, args []string) error {
+	root, err := statTree(a, "tree", args, a.opts.depth)
+	if err != nil {
+		return err
+	}
+	if a.opts.json {
+		return printJSON(root)
+	}
+
+	var dirs, files int
+	var walk func(entry *remoteEntry, prefix string)
+	walk = func(entry *remoteEntry, prefix string) {
+		for i, child := range entry.Children {
+			branch, indent := "├── ", "│   "
+			if i == len(entry.Children)-1 {
+				branch, indent = "└── ", "    "
+			}
+			line := prefix + branch + child.displayName()
+			if a.opts.long && !child.IsDir {
+				line += fmt.Sprintf("  (%s, %s)", util.FormatSize(child.Size), child.modTime())
+			}
+			fmt.Println(line)
+			if child.IsDir {
+				dirs++
+				walk(child, prefix+indent)
+			} else {
+				files++
+			}
+		}
+	}
+	fmt.Println(root.Path)
+	walk(root, "")
+	fmt.Printf("\n%d 个目录，%d 个文件\n", dirs, files)
+	return nil
+}
+
+// duRow du 命令输出的一行，统计一个目录中的全部文件
+type duRow struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`  // 目录中（包括子目录）所有文件的大小之和
+	Files int    `json:"files"` // 目录中（包括子目录）的文件数
+	Dirs  int    `json:"dirs"`  // 目录中（包括子目录）的目录数
+}
+
+// runDu 处理 du 命令，统计每个目录占用的空间和文件数，子目录在上级目录之前输出
+//
+//	du [-depth 层数] [-json] [远程目录]  -depth 只影响显示的层数，统计总是包括全部子目录
+func runDu(a *app, args []string) error {
+	root, err := statTree(a, "du", args, 0)
+	if err != nil {
+		return err
+	}
+
+	var rows []duRow
+	var sum func(entry *remoteEntry, level int) duRow
+	sum = func(entry *remoteEntry, level int) duRow {
+		row := duRow{Path: entry.Path}
+		if !entry.IsDir {
+			row.Size, row.Files = entry.Size, 1
+			return row
+		}
+		for _, child := range entry.Children {
+			r := sum(child, level+1)
+			row.Size += r.Size
+			row.Files += r.Files
+			row.Dirs += r.Dirs
+			if child.IsDir {
+				row.Dirs++
+			}
+		}
+		if a.opts.depth == 0 || level <= a.opts.depth {
+			rows = append(rows, row)
+		}
+		return row
+	}
+	sum(root, 0)
+
+	if a.opts.json {
+		return printJSON(rows)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "大小\t文件数\t路径")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", util.FormatSize(row.Size), row.Files, row.Path)
+	}
+	return w.Flush()
+}